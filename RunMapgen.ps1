@@ -0,0 +1,10 @@
+try
+{
+    clear
+    sl cmd/mapgen
+    go run . -heights heights.png -biomes biomes.png
+}
+finally
+{
+    sl ../../
+}
\ No newline at end of file
@@ -0,0 +1,127 @@
+package mmo
+
+import (
+	"fmt"
+	"gommo/engine/asset"
+	"gommo/engine/tilemap"
+)
+
+const biomesJson = "assets/biomes.json"
+
+type Decoration struct {
+	Tile   string  `json:"tile"`
+	Chance float64 `json:"chance"`
+
+	tileType tilemap.TileType
+}
+
+// Biome applies to tiles whose height based terrain matches Terrain and whose moisture and
+// temperature fall within the given [min, max] ranges. A missing range matches anything.
+type Biome struct {
+	Name        string       `json:"name"`
+	Color       string       `json:"color"`
+	Terrain     string       `json:"terrain"`
+	Moisture    []float64    `json:"moisture"`
+	Temperature []float64    `json:"temperature"`
+	Ground      string       `json:"ground"`
+	Decorations []Decoration `json:"decorations"`
+
+	terrainType tilemap.TileType
+	groundType  tilemap.TileType
+}
+
+func (biome *Biome) GroundType() tilemap.TileType {
+	return biome.groundType
+}
+
+// Decoration picks a decoration from a roll in [0, 1), using each decoration's chance
+// as its share of the roll.
+func (biome *Biome) Decoration(roll float64) (tilemap.TileType, bool) {
+	for _, decoration := range biome.Decorations {
+		if roll < decoration.Chance {
+			return decoration.tileType, true
+		}
+		roll -= decoration.Chance
+	}
+	return 0, false
+}
+
+func inRange(r []float64, v float64) bool {
+	return len(r) == 0 || (v >= r[0] && v <= r[1])
+}
+
+// BiomeTable is searched in order, so more specific biomes should come first.
+type BiomeTable struct {
+	Biomes []Biome
+}
+
+func NewBiomeTable(biomes []Biome, tiles *tilemap.Registry) (*BiomeTable, error) {
+	if len(biomes) > 255 {
+		return nil, fmt.Errorf("too many biomes: %d", len(biomes))
+	}
+	resolve := func(biome string, name string) (tilemap.TileType, error) {
+		tileType, ok := tiles.ByName(name)
+		if !ok {
+			return 0, fmt.Errorf("biome %q uses unknown tile %q", biome, name)
+		}
+		return tileType, nil
+	}
+
+	for i := range biomes {
+		biome := &biomes[i]
+		var err error
+		biome.terrainType, err = resolve(biome.Name, biome.Terrain)
+		if err != nil {
+			return nil, err
+		}
+		biome.groundType, err = resolve(biome.Name, biome.Ground)
+		if err != nil {
+			return nil, err
+		}
+		for j := range biome.Decorations {
+			biome.Decorations[j].tileType, err = resolve(biome.Name, biome.Decorations[j].Tile)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if (len(biome.Moisture) != 0 && len(biome.Moisture) != 2) || (len(biome.Temperature) != 0 && len(biome.Temperature) != 2) {
+			return nil, fmt.Errorf("biome %q ranges must be [min, max]", biome.Name)
+		}
+	}
+	return &BiomeTable{Biomes: biomes}, nil
+}
+
+// Find returns the index of the first biome matching the terrain, moisture and temperature.
+func (table *BiomeTable) Find(terrain tilemap.TileType, moisture float64, temperature float64) (int, bool) {
+	for i := range table.Biomes {
+		biome := &table.Biomes[i]
+		if biome.terrainType == terrain && inRange(biome.Moisture, moisture) && inRange(biome.Temperature, temperature) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func (table *BiomeTable) Get(i int) (*Biome, bool) {
+	if i < 0 || i >= len(table.Biomes) {
+		return nil, false
+	}
+	return &table.Biomes[i], true
+}
+
+// Biomes maps moisture and temperature to the tiles used on each kind of terrain.
+var Biomes = loadBiomes()
+
+func loadBiomes() *BiomeTable {
+	biomes := []Biome{}
+	err := asset.NewLoad(assets).Json(biomesJson, &biomes)
+	if err != nil {
+		panic(err)
+	}
+
+	table, err := NewBiomeTable(biomes, Tiles)
+	if err != nil {
+		panic(err)
+	}
+	return table
+}
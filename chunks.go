@@ -0,0 +1,64 @@
+package mmo
+
+import (
+	"gommo/engine/ecs"
+	"gommo/engine/physics"
+	"gommo/engine/tilemap"
+	"time"
+)
+
+const (
+	DefaultLoadRadius = 2
+	// Chunks nothing has read for chunkIdle are dropped, checked every evictInterval
+	chunkIdle     = 30 * time.Second
+	evictInterval = 10 * time.Second
+)
+
+// KeepLoaded marks an entity, usually a player, that keeps the chunks within Radius
+// chunks of it generated.
+type KeepLoaded struct {
+	Radius int
+}
+
+func (keep *KeepLoaded) ComponentSet(val interface{}) { *keep = val.(KeepLoaded) }
+
+// CreateChunkSystems keeps the chunks around every KeepLoaded entity generated and
+// periodically drops the ones no one is near. Maps held whole don't need any.
+func CreateChunkSystems(engine *ecs.Engine, tmap tilemap.Map) []ecs.System {
+	chunked, ok := tmap.(*tilemap.ChunkedTilemap)
+	if !ok {
+		return []ecs.System{}
+	}
+	return []ecs.System{
+		{Name: "LoadChunks", Func: loadChunksFunc(engine, chunked)},
+		{Name: "EvictChunks", Func: evictChunksFunc(chunked)},
+	}
+}
+
+func loadChunksFunc(engine *ecs.Engine, chunked *tilemap.ChunkedTilemap) func(dt time.Duration) {
+	return func(dt time.Duration) {
+		ecs.Each(engine, KeepLoaded{}, func(id ecs.Id, a interface{}) {
+			keep := a.(KeepLoaded)
+
+			transform := physics.Transform{}
+			ok := ecs.Read(engine, id, &transform)
+			if !ok {
+				return
+			}
+			x, y := chunked.WorldToTile(transform.X, transform.Y)
+			chunked.Load(chunked.ToChunk(x, y), keep.Radius)
+		})
+	}
+}
+
+func evictChunksFunc(chunked *tilemap.ChunkedTilemap) func(dt time.Duration) {
+	elapsed := time.Duration(0)
+	return func(dt time.Duration) {
+		elapsed += dt
+		if elapsed < evictInterval {
+			return
+		}
+		elapsed = 0
+		chunked.EvictIdle(chunkIdle)
+	}
+}
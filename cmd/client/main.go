@@ -9,6 +9,7 @@ import (
 	mmo "gommo"
 	"gommo/engine/asset"
 	"gommo/engine/ecs"
+	"gommo/engine/network"
 	"gommo/engine/physics"
 	"gommo/engine/render"
 	"gommo/engine/tilemap"
@@ -22,25 +23,57 @@ import (
 
 func main() {
 	conn := createConnection()
-	go sendCounterToServer(conn)
+	go sendToServer(conn)
+	go sendCounterToServer()
+	go receiveFromServer(conn)
 	pixelgl.Run(runGame)
 }
 
-func sendCounterToServer(conn net.Conn) {
-	func() {
-		counter := byte(0)
-		for {
-			time.Sleep(1 * time.Second)
-			n, err := conn.Write([]byte{counter})
-			if err != nil {
-				log.Println("error sending:", err)
-				return
-			}
+func sendCounterToServer() {
+	counter := byte(0)
+	for {
+		time.Sleep(1 * time.Second)
+		msg, err := network.Message{Type: uint8(mmo.PingMessage), Payload: []byte{counter}}.MarshalBinary()
+		check(err)
+		send(msg)
+		counter++
+	}
+}
+
+// send queues a message for sendToServer, dropping it if the connection can't keep up so
+// the game loop never blocks on the network.
+func send(msg []byte) {
+	select {
+	case outgoing <- msg:
+	default:
+		log.Println("dropped outgoing message")
+	}
+}
 
-			log.Println("sent n bytes: ", n)
-			counter++
+// sendToServer writes every queued message to the server.
+func sendToServer(conn net.Conn) {
+	for msg := range outgoing {
+		_, err := conn.Write(msg)
+		if err != nil {
+			log.Println("error sending:", err)
+			return
 		}
-	}()
+	}
+}
+
+// receiveFromServer queues every message from the server, to be handled on the game loop by
+// the ReceiveMessages system.
+func receiveFromServer(conn net.Conn) {
+	reader := network.NewReader(conn, network.DefaultMaxMessageSize)
+	for {
+		msg, err := reader.Read()
+		if err != nil {
+			log.Println("error receiving:", err)
+			close(messages)
+			return
+		}
+		messages <- msg
+	}
 }
 
 func createConnection() net.Conn {
@@ -69,10 +102,8 @@ const (
 	windowsResizable = true
 	purpleGemPng     = "purple.png"
 	redGemPng        = "red.png"
-	waterPng         = "water.png"
-	sandPng          = "sand.png"
-	grassPng         = "grass.png"
 	packedJson       = "packed.json"
+	messageBuffer    = 256
 )
 
 var load *asset.Load
@@ -80,6 +111,11 @@ var spritesheet *asset.Spritesheet
 var window *pixelgl.Window
 var engine *ecs.Engine
 var playerId ecs.Id
+var messages = make(chan network.Message, messageBuffer)
+var outgoing = make(chan []byte, messageBuffer)
+
+// remotePlayers maps the server's ids of other players to their entities here
+var remotePlayers = make(map[ecs.Id]ecs.Id)
 
 func runGame() {
 	setupGame()
@@ -87,32 +123,163 @@ func runGame() {
 }
 
 func runGameLoop() {
-	tmap, purpleGemId, redGemId := mmo.LoadGame(engine)
+	world := receiveWorld()
+	tmap := world.Tilemap
+	purpleGemId, redGemId := mmo.LoadGameWithTilemap(engine, tmap, world.Config)
 	playerId = purpleGemId
 	createPeople(spritesheet, purpleGemId, redGemId)
-	tmapRenderer := createTileMapRender(tmap)
-	gameLoop(tmapRenderer)
+	tmapRenderer := createTileMapRender(tmap, world.Config)
+	gameLoop(tmap, tmapRenderer)
+}
+
+// receiveWorld waits for the server to send its world. Anything sent before it is about a
+// world the client doesn't have yet, and is already part of the snapshot.
+func receiveWorld() mmo.WorldState {
+	for msg := range messages {
+		if mmo.MessageType(msg.Type) != mmo.WorldMessage {
+			continue
+		}
+		world := mmo.WorldState{}
+		err := world.UnmarshalBinary(msg.Payload)
+		check(err)
+		return world
+	}
+	panic("connection closed before the world was received")
 }
 
-func gameLoop(tmapRender *render.TilemapRender) {
+func gameLoop(tmap tilemap.Map, tmapRender *render.TilemapRender) {
 	camera, zoomSpeed := createCamera()
 	quit := ecs.Signal{}
 	quit.Set(false)
 
-	inputSystems := createInputSystems(camera, zoomSpeed, &quit)
-	physicsSystems := mmo.CreatePhysicsSystems(engine)
-	renderSystems := createRenderSystems(tmapRender, camera)
+	paths := mmo.NewPaths(engine, tmap)
+	defer paths.Close()
+
+	inputSystems := createInputSystems(tmap, paths, camera, zoomSpeed, &quit)
+	physicsSystems := mmo.CreatePhysicsSystems(engine, tmap)
+	physicsSystems = append(physicsSystems, mmo.CreateChunkSystems(engine, tmap)...)
+	renderSystems := createRenderSystems(tmap, tmapRender, camera)
 
 	ecs.RunGame(inputSystems, physicsSystems, renderSystems, &quit)
 }
 
-func createInputSystems(camera *render.Camera, zoomSpeed float64, quit *ecs.Signal) []ecs.System {
-	return []ecs.System{
+func createInputSystems(tmap tilemap.Map, paths *mmo.Paths, camera *render.Camera, zoomSpeed float64, quit *ecs.Signal) []ecs.System {
+	movement := mmo.NewMovement(tmap)
+	inputSystems := []ecs.System{
+		{Name: "ReceiveMessages", Func: receiveMessagesFunc(tmap, movement)},
 		{Name: "UpdateCameraZoom", Func: updateCameraZoomFunc(camera, zoomSpeed)},
 		{Name: "exitGame", Func: exitGameFunc(quit)},
 		{Name: "Clear", Func: clearFunc()},
 		{Name: "CaptureInput", Func: captureInputFunc()},
+		{Name: "ClickToMove", Func: clickToMoveFunc(paths, camera)},
+	}
+	inputSystems = append(inputSystems, mmo.CreatePathSystems(engine, paths)...)
+	return append(inputSystems, ecs.System{Name: "RecordInputs", Func: recordInputsFunc()})
+}
+
+func receiveMessagesFunc(tmap tilemap.Map, movement physics.Movement) func(dt time.Duration) {
+	return func(dt time.Duration) {
+		for {
+			select {
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				handleMessage(tmap, movement, msg)
+			default:
+				return
+			}
+		}
+	}
+}
+
+func handleMessage(tmap tilemap.Map, movement physics.Movement, msg network.Message) {
+	switch mmo.MessageType(msg.Type) {
+	case mmo.SpawnMessage:
+		spawn := physics.Transform{}
+		err := spawn.UnmarshalBinary(msg.Payload)
+		if err != nil {
+			log.Println("invalid spawn:", err)
+			return
+		}
+		ecs.Write(engine, playerId, spawn)
+		prediction := physics.Prediction{}
+		if ecs.Read(engine, playerId, &prediction) {
+			prediction.OffsetX, prediction.OffsetY = 0, 0
+			ecs.Write(engine, playerId, prediction)
+		}
+	case mmo.InputAckMessage:
+		ack := physics.InputAck{}
+		err := ack.UnmarshalBinary(msg.Payload)
+		if err != nil {
+			log.Println("invalid input ack:", err)
+			return
+		}
+		reconcile(movement, ack)
+	case mmo.EntityTransformMessage:
+		entity := mmo.EntityTransform{}
+		err := entity.UnmarshalBinary(msg.Payload)
+		if err != nil {
+			log.Println("invalid entity transform:", err)
+			return
+		}
+		id := remotePlayer(entity.Id)
+		physics.AddSnapshot(engine, id, physics.Snapshot{Time: time.Now(), Transform: entity.Transform})
+	case mmo.EntityRemovedMessage:
+		removal := mmo.EntityRemoval{}
+		err := removal.UnmarshalBinary(msg.Payload)
+		if err != nil {
+			log.Println("invalid entity removal:", err)
+			return
+		}
+		id, ok := remotePlayers[removal.Id]
+		if ok {
+			ecs.Delete(engine, id)
+			delete(remotePlayers, removal.Id)
+		}
+	case mmo.TileChangeMessage:
+		change := tilemap.Change{}
+		err := change.UnmarshalBinary(msg.Payload)
+		if err != nil {
+			log.Println("invalid tile change:", err)
+			return
+		}
+		// Only maps held whole can be edited, so servers with chunked worlds never send changes
+		editable, ok := tmap.(tilemap.Editable)
+		if ok {
+			editable.Apply(change.Cells)
+		}
+	default:
+		log.Println("unknown message type:", msg.Type)
+	}
+}
+
+// remotePlayer returns the entity drawing another player, creating it the first time the
+// server mentions them.
+func remotePlayer(serverId ecs.Id) ecs.Id {
+	id, ok := remotePlayers[serverId]
+	if ok {
+		return id
 	}
+	sprite, err := spritesheet.Get(redGemPng)
+	check(err)
+	id = engine.NewId()
+	ecs.Write(engine, id, render.Sprite{Sprite: sprite})
+	remotePlayers[serverId] = id
+	return id
+}
+
+// reconcile moves the player to where the server says it is, replaying every input the
+// server hasn't applied yet.
+func reconcile(movement physics.Movement, ack physics.InputAck) {
+	prediction := physics.Prediction{}
+	transform := physics.Transform{}
+	if !ecs.Read(engine, playerId, &prediction) || !ecs.Read(engine, playerId, &transform) {
+		return
+	}
+	transform = prediction.Reconcile(transform, ack.Transform, ack.Sequence, movement)
+	ecs.Write(engine, playerId, transform)
+	ecs.Write(engine, playerId, prediction)
 }
 
 func clearFunc() func(dt time.Duration) {
@@ -138,10 +305,10 @@ func updateCameraZoomFunc(camera *render.Camera, zoomSpeed float64) func(dt time
 	}
 }
 
-func createRenderSystems(tmapRender *render.TilemapRender, camera *render.Camera) []ecs.System {
+func createRenderSystems(tmap tilemap.Map, tmapRender *render.TilemapRender, camera *render.Camera) []ecs.System {
 	return []ecs.System{
 		{Name: "UpdateCamera", Func: updateCameraFunc(camera)},
-		{Name: "Draw", Func: drawFunc(tmapRender, camera)},
+		{Name: "Draw", Func: drawFunc(tmap, tmapRender, camera)},
 		{Name: "UpdateWindow", Func: updateWindowFunc()},
 	}
 }
@@ -157,12 +324,14 @@ func updateCameraFunc(camera *render.Camera) func(dt time.Duration) {
 	}
 }
 
-func drawFunc(tmapRender *render.TilemapRender, camera *render.Camera) func(dt time.Duration) {
+func drawFunc(tmap tilemap.Map, tmapRender *render.TilemapRender, camera *render.Camera) func(dt time.Duration) {
 	return func(dt time.Duration) {
 		window.SetMatrix(camera.Matrix())
-		tmapRender.Draw(window)
-
-		render.DrawSprites(window, engine)
+		view := camera.View()
+		tmapRender.Update(tmap, view)
+		tmapRender.DrawGround(window, view)
+		render.DrawSprites(window, engine, render.DefaultInterpolationSettings)
+		tmapRender.DrawOverlay(window, view)
 
 		window.SetMatrix(pixel.IM)
 	}
@@ -180,20 +349,43 @@ func captureInputFunc() func(dt time.Duration) {
 	}
 }
 
-func createTileMapRender(tmap *tilemap.Tilemap) *render.TilemapRender {
-	grassTile, err := spritesheet.Get(grassPng)
-	check(err)
-	sandTile, err := spritesheet.Get(sandPng)
-	check(err)
-	waterTile, err := spritesheet.Get(waterPng)
-	check(err)
+// clickToMoveFunc walks the player to wherever the right mouse button is clicked.
+func clickToMoveFunc(paths *mmo.Paths, camera *render.Camera) func(dt time.Duration) {
+	return func(dt time.Duration) {
+		if window.JustPressed(pixelgl.MouseButtonRight) {
+			target := camera.Matrix().Unproject(window.MousePosition())
+			paths.MoveTo(playerId, target.X, target.Y)
+		}
+	}
+}
+
+func recordInputsFunc() func(dt time.Duration) {
+	return func(dt time.Duration) {
+		for _, input := range physics.RecordInputs(engine) {
+			msg, err := mmo.EncodeMessage(mmo.InputMessage, input)
+			if err != nil {
+				log.Println("error encoding input:", err)
+				continue
+			}
+			send(msg)
+		}
+	}
+}
+
+func createTileMapRender(tmap tilemap.Map, config mmo.WorldConfig) *render.TilemapRender {
+	tileToSprite := make(map[tilemap.TileType]*pixel.Sprite)
+	for _, tileType := range mmo.Tiles.Types() {
+		def, _ := mmo.Tiles.Get(tileType)
+		sprite, err := spritesheet.Get(def.Sprite)
+		check(err)
+		tileToSprite[tileType] = sprite
+	}
 
-	tmapRender := render.NewTilemapRender(spritesheet, map[tilemap.TileType]*pixel.Sprite{
-		mmo.GrassTile: grassTile,
-		mmo.SandTile:  sandTile,
-		mmo.WaterTile: waterTile,
-	})
-	tmapRender.Batch(tmap)
+	tmapRender := render.NewTilemapRender(spritesheet, tileToSprite, objectSprites(mmo.NewObjectPlacer(tmap, config, mmo.WorldStreams(engine))))
+	editable, ok := tmap.(tilemap.Editable)
+	if ok {
+		editable.OnChange(tmapRender.MarkDirty)
+	}
 	return tmapRender
 }
 
@@ -208,6 +400,8 @@ func createPeople(spritesheet *asset.Spritesheet, purpleGemId ecs.Id, redGemId e
 	check(err)
 	ecs.Write(engine, purpleGemId, render.Sprite{Sprite: purpleGemSprite})
 	ecs.Write(engine, purpleGemId, render.AWSDKeybinds)
+	ecs.Write(engine, purpleGemId, physics.NewPrediction(physics.DefaultPredictionHistory))
+	ecs.Write(engine, purpleGemId, mmo.KeepLoaded{Radius: mmo.DefaultLoadRadius})
 
 	redGemSprite, err := spritesheet.Get(redGemPng)
 	check(err)
@@ -215,6 +409,30 @@ func createPeople(spritesheet *asset.Spritesheet, purpleGemId ecs.Id, redGemId e
 	ecs.Write(engine, redGemId, render.ArrowKeybinds)
 }
 
+// objectSprites draws world objects as part of the tilemap. There are thousands of them and
+// they never move, so they aren't drawn as sprites. They are placed chunk by chunk from the
+// world config, the same as the object entities, so chunked worlds get them too.
+func objectSprites(placer *mmo.ObjectPlacer) render.StaticFunc {
+	return func(minX, minY, maxX, maxY int) []render.StaticSprite {
+		statics := []render.StaticSprite{}
+		size := tilemap.DefaultChunkSize
+		for chunkX := minX / size; chunkX*size < maxX; chunkX++ {
+			for chunkY := minY / size; chunkY*size < maxY; chunkY++ {
+				for _, object := range placer.Chunk(chunkX, chunkY) {
+					tile := object.Tile
+					if tile.X < minX || tile.X >= maxX || tile.Y < minY || tile.Y >= maxY {
+						continue
+					}
+					sprite, err := spritesheet.Get(object.Definition.Sprite)
+					check(err)
+					statics = append(statics, render.StaticSprite{Sprite: sprite, Position: pixel.V(object.X, object.Y)})
+				}
+			}
+		}
+		return statics
+	}
+}
+
 func setupGame() {
 	setupAssets()
 	setupEngine()
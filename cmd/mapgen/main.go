@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	mmo "gommo"
+	"gommo/engine/asset"
+	"gommo/engine/proceduralgeneration"
+	"gommo/engine/random"
+	"gommo/engine/tilemap"
+	"image"
+	"image/color"
+	"image/png"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// mapgen writes PNG previews of generated maps, one pixel per tile, without needing a display.
+func main() {
+	worldPath := flag.String("world", "", "world generation config, defaults to the built in world")
+	out := flag.String("out", "map.png", "tile type preview to write")
+	heightsOut := flag.String("heights", "", "optional grayscale heightmap to write")
+	biomesOut := flag.String("biomes", "", "optional biome map to write")
+	decorations := flag.Bool("decorations", false, "draw decorations over the ground")
+	dungeonKind := flag.String("dungeon", "", "preview a dungeon instead of the world: cave or rooms")
+	dungeonSize := flag.Int("dungeon-size", 80, "width and height of the dungeon")
+	bench := flag.Int("bench", 0, "time this many world generations with one worker and in parallel, checking they match")
+	flag.Parse()
+
+	config := mmo.DefaultWorldConfig
+	if *worldPath != "" {
+		var err error
+		load := asset.NewLoad(os.DirFS(filepath.Dir(*worldPath)))
+		config, err = mmo.LoadWorldConfig(load, filepath.Base(*worldPath))
+		check(err)
+	}
+
+	if *bench > 0 {
+		benchmark(config, *bench)
+		return
+	}
+
+	start := time.Now()
+	if *dungeonKind != "" {
+		kind := mmo.CaveDungeon
+		switch *dungeonKind {
+		case "cave":
+		case "rooms":
+			kind = mmo.RoomDungeon
+		default:
+			log.Fatalf("unknown dungeon kind %q", *dungeonKind)
+		}
+		dungeon, err := mmo.CreateDungeon(kind, random.New(config.Seed), *dungeonSize, *dungeonSize, config.TileSize)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Println("generated dungeon in", time.Since(start))
+		log.Printf("%d monster spawns, %d loot", len(dungeon.Spawns), len(dungeon.Loot))
+		writePng(*out, tileImage(dungeon.Tilemap, *decorations))
+		return
+	}
+
+	world := mmo.GenerateWorld(config)
+	log.Println("generated world in", time.Since(start))
+	printStats(mmo.Stats(world.Tilemap))
+
+	writePng(*out, tileImage(world.Tilemap, *decorations))
+	if *heightsOut != "" {
+		writePng(*heightsOut, heightImage(world.Heights))
+	}
+	if *biomesOut != "" {
+		writePng(*biomesOut, biomeImage(world))
+	}
+}
+
+// benchmark compares sequential and parallel generation. With a single CPU it still runs
+// several workers, to check the output doesn't depend on them.
+func benchmark(config mmo.WorldConfig, runs int) {
+	workers := []int{1, proceduralgeneration.DefaultWorkers}
+	if proceduralgeneration.DefaultWorkers == 1 {
+		workers[1] = 4
+	}
+
+	var reference []byte
+	var sequential time.Duration
+	for _, n := range workers {
+		var total time.Duration
+		var encoded bytes.Buffer
+		for i := 0; i < runs; i++ {
+			start := time.Now()
+			world := mmo.GenerateWorldParallel(config, n)
+			total += time.Since(start)
+
+			encoded.Reset()
+			check(tilemap.Save(&encoded, world.Tilemap, config.Seed))
+		}
+
+		average := total / time.Duration(runs)
+		if reference == nil {
+			reference, sequential = append([]byte(nil), encoded.Bytes()...), average
+		}
+		fmt.Printf("%2d workers: %v per world, %.2fx, identical: %v\n",
+			n, average, float64(sequential)/float64(average), bytes.Equal(reference, encoded.Bytes()))
+	}
+}
+
+func check(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
+
+func printStats(stats mmo.MapStats) {
+	fmt.Printf("land: %.1f%%\n", stats.Land*100)
+	fmt.Printf("islands: %d\n", stats.Islands)
+	fmt.Printf("lakes: %d\n", stats.Lakes)
+	fmt.Printf("towns: %d\n", stats.Towns)
+
+	total := 0
+	types := []tilemap.TileType{}
+	for tileType, count := range stats.Tiles {
+		total += count
+		types = append(types, tileType)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	for _, tileType := range types {
+		def, _ := mmo.Tiles.Get(tileType)
+		fmt.Printf("  %-10s %5.1f%%\n", def.Name, float64(stats.Tiles[tileType])/float64(total)*100)
+	}
+}
+
+// newImage creates an image for the map. Tile y grows upwards while image y grows
+// downwards, so callers set pixels through the returned function.
+func newImage(width int, height int) (*image.NRGBA, func(x, y int, c color.Color)) {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	return img, func(x, y int, c color.Color) {
+		img.Set(x, height-1-y, c)
+	}
+}
+
+func tileImage(tmap *tilemap.Tilemap, decorations bool) image.Image {
+	colors := make(map[tilemap.TileType]color.Color)
+	for _, tileType := range mmo.Tiles.Types() {
+		value, _ := mmo.Tiles.Property(tileType, "color")
+		hex, _ := value.(string)
+		colors[tileType] = parseColor(hex)
+	}
+
+	img, set := newImage(tmap.Width(), tmap.Height())
+	for x := 0; x < tmap.Width(); x++ {
+		for y := 0; y < tmap.Height(); y++ {
+			tile, _ := tmap.Get(x, y)
+			if decoration, ok := tmap.GetLayer(tilemap.DecorationLayer, x, y); decorations && ok && decoration.Type != tilemap.EmptyTile {
+				tile = decoration
+			}
+			set(x, y, colors[tile.Type])
+		}
+	}
+	return img
+}
+
+func heightImage(heights *proceduralgeneration.Heightmap) image.Image {
+	img, set := newImage(heights.Width, heights.Height)
+	for x := 0; x < heights.Width; x++ {
+		for y := 0; y < heights.Height; y++ {
+			set(x, y, color.Gray{Y: uint8(proceduralgeneration.Unit(heights.Get(x, y)) * 255)})
+		}
+	}
+	return img
+}
+
+func biomeImage(world *mmo.World) image.Image {
+	img, set := newImage(world.Tilemap.Width(), world.Tilemap.Height())
+	for x := 0; x < world.Tilemap.Width(); x++ {
+		for y := 0; y < world.Tilemap.Height(); y++ {
+			biome, ok := world.Biome(x, y)
+			if ok {
+				set(x, y, parseColor(biome.Color))
+			}
+		}
+	}
+	return img
+}
+
+// parseColor reads a #rrggbb colour, falling back to magenta so missing colours stand out.
+func parseColor(hex string) color.Color {
+	c := color.NRGBA{A: 255}
+	_, err := fmt.Sscanf(hex, "#%02x%02x%02x", &c.R, &c.G, &c.B)
+	if err != nil {
+		return color.NRGBA{R: 255, B: 255, A: 255}
+	}
+	return c
+}
+
+func writePng(path string, img image.Image) {
+	file, err := os.Create(path)
+	check(err)
+	defer file.Close()
+	check(png.Encode(file, img))
+	log.Println("wrote", path)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"log"
+	"net"
+	"sync"
+)
+
+const clientSendBuffer = 256
+
+// clientList fans messages out to every connected client. Each client has its own
+// writer goroutine so a slow connection never blocks the game loop. The list never closes
+// connections itself, that is left to whoever added them.
+type clientList struct {
+	mu      sync.Mutex
+	clients map[net.Conn]*client
+}
+
+type client struct {
+	send    chan []byte
+	dropped chan struct{}
+}
+
+func newClientList() *clientList {
+	return &clientList{clients: make(map[net.Conn]*client)}
+}
+
+// Add starts sending to conn. The returned channel is closed once the client is removed,
+// whether by Remove, a failed write or falling too far behind.
+func (list *clientList) Add(conn net.Conn) <-chan struct{} {
+	c := &client{
+		send:    make(chan []byte, clientSendBuffer),
+		dropped: make(chan struct{}),
+	}
+
+	list.mu.Lock()
+	list.clients[conn] = c
+	list.mu.Unlock()
+
+	go func() {
+		for msg := range c.send {
+			_, err := conn.Write(msg)
+			if err != nil {
+				log.Println("error sending:", err)
+				list.Remove(conn)
+				return
+			}
+		}
+	}()
+	return c.dropped
+}
+
+func (list *clientList) Remove(conn net.Conn) {
+	list.mu.Lock()
+	defer list.mu.Unlock()
+	list.remove(conn)
+}
+
+// remove must be called with the lock held.
+func (list *clientList) remove(conn net.Conn) {
+	c, ok := list.clients[conn]
+	if !ok {
+		return
+	}
+	delete(list.clients, conn)
+	close(c.send)
+	close(c.dropped)
+}
+
+// Send queues a message for a single client.
+func (list *clientList) Send(conn net.Conn, msg []byte) {
+	list.mu.Lock()
+	defer list.mu.Unlock()
+
+	c, ok := list.clients[conn]
+	if ok {
+		list.queue(conn, c, msg)
+	}
+}
+
+// Broadcast queues a message for every client, dropping clients that have fallen too far behind.
+func (list *clientList) Broadcast(msg []byte) {
+	list.mu.Lock()
+	defer list.mu.Unlock()
+
+	for conn, c := range list.clients {
+		list.queue(conn, c, msg)
+	}
+}
+
+// BroadcastExcept queues a message for every client but one, usually the one it is about.
+func (list *clientList) BroadcastExcept(except net.Conn, msg []byte) {
+	list.mu.Lock()
+	defer list.mu.Unlock()
+
+	for conn, c := range list.clients {
+		if conn != except {
+			list.queue(conn, c, msg)
+		}
+	}
+}
+
+// queue must be called with the lock held.
+func (list *clientList) queue(conn net.Conn, c *client, msg []byte) {
+	select {
+	case c.send <- msg:
+	default:
+		log.Println("client send buffer full, disconnecting:", conn.RemoteAddr())
+		list.remove(conn)
+	}
+}
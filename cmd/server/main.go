@@ -2,28 +2,70 @@ package main
 
 import (
 	"context"
+	"flag"
 	mmo "gommo"
+	"gommo/engine/asset"
 	"gommo/engine/ecs"
+	"gommo/engine/network"
+	"gommo/engine/physics"
+	"gommo/engine/tilemap"
 	"log"
 	"net"
 	"net/http"
 	"nhooyr.io/websocket"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"time"
 )
 
 func main() {
+	mapPath := flag.String("map", "", "tilemap file to load the world from and save it to on shutdown")
+	worldPath := flag.String("world", "", "world generation config used when there is no saved tilemap")
+	flag.Parse()
+
+	config := mmo.DefaultWorldConfig
+	if *worldPath != "" {
+		var err error
+		load := asset.NewLoad(os.DirFS(filepath.Dir(*worldPath)))
+		config, err = mmo.LoadWorldConfig(load, filepath.Base(*worldPath))
+		if err != nil {
+			panic(err)
+		}
+	}
+
 	// Load Game
 	engine := ecs.NewEngine()
-	_, _, _ = mmo.LoadGame(engine)
+	tmap, config, err := mmo.LoadTilemap(*mapPath, config)
+	if err != nil {
+		panic(err)
+	}
+	_, _ = mmo.LoadGameWithTilemap(engine, tmap, config)
+
+	clients := newClientList()
+	editable, ok := tmap.(tilemap.Editable)
+	if ok {
+		editable.OnChange(func(change tilemap.Change) {
+			msg, err := mmo.EncodeMessage(mmo.TileChangeMessage, change)
+			if err != nil {
+				log.Println("error encoding tile change:", err)
+				return
+			}
+			clients.Broadcast(msg)
+		})
+	}
 
-	physicsSystems := mmo.CreatePhysicsSystems(engine)
+	players := newPlayerList(engine, clients, mmo.NewMovement(tmap))
+	inputSystems := []ecs.System{
+		{Name: "UpdatePlayers", Func: updatePlayersFunc(players)},
+	}
+	physicsSystems := mmo.CreatePhysicsSystems(engine, tmap)
+	physicsSystems = append(physicsSystems, mmo.CreateChunkSystems(engine, tmap)...)
 
 	quit := ecs.Signal{}
 	quit.Set(false)
 
-	go ecs.RunGame([]ecs.System{}, physicsSystems, []ecs.System{}, &quit)
+	go ecs.RunGame(inputSystems, physicsSystems, []ecs.System{}, &quit)
 
 	listener, err := net.Listen("tcp", ":8000")
 	if err != nil {
@@ -31,7 +73,7 @@ func main() {
 	}
 
 	server := &http.Server{
-		Handler:      websocketServer{},
+		Handler:      websocketServer{world: mmo.WorldState{Config: config, Tilemap: tmap}, clients: clients, players: players, spawner: mmo.NewSpawner(tmap, config, mmo.WorldStreams(engine))},
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
 	}
@@ -62,9 +104,28 @@ func main() {
 	if err != nil {
 		log.Println("error shutting down", err)
 	}
+
+	// Chunked worlds are refused a map path when loading, so they never get here
+	whole, ok := tmap.(*tilemap.Tilemap)
+	if ok && *mapPath != "" {
+		err = mmo.SaveTilemap(*mapPath, whole, config.Seed)
+		if err != nil {
+			log.Println("error saving tilemap:", err)
+		}
+	}
+}
+
+func updatePlayersFunc(players *playerList) func(dt time.Duration) {
+	return func(dt time.Duration) {
+		players.Update(dt)
+	}
 }
 
 type websocketServer struct {
+	world   mmo.WorldState
+	clients *clientList
+	players *playerList
+	spawner *mmo.Spawner
 }
 
 func (s websocketServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -77,10 +138,16 @@ func (s websocketServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx := context.Background()
 	conn := websocket.NetConn(ctx, c, websocket.MessageBinary)
 
-	go ServeNetConn(conn)
+	// Players can ask to join in a specific spawn region with ?spawn=name
+	spawnRegion := r.URL.Query().Get("spawn")
+	go ServeNetConn(conn, s.world, s.clients, s.players, s.spawner, spawnRegion)
 }
 
-func ServeNetConn(conn net.Conn) {
+func ServeNetConn(conn net.Conn, world mmo.WorldState, clients *clientList, players *playerList, spawner *mmo.Spawner, spawnRegion string) {
+	dropped := clients.Add(conn)
+	defer clients.Remove(conn)
+
+	// This is the only place the connection is closed, which also stops the reader below
 	defer func() {
 		err := conn.Close()
 		if err != nil {
@@ -88,6 +155,22 @@ func ServeNetConn(conn net.Conn) {
 		}
 	}()
 
+	// The client is already receiving tile changes, so any edit made while the world is
+	// being sent is in the snapshot, the broadcast or both.
+	err := sendWorld(conn, world, clients)
+	if err != nil {
+		log.Println("error sending world:", err)
+		return
+	}
+
+	spawn, err := sendSpawn(conn, spawner, spawnRegion, clients)
+	if err != nil {
+		log.Println("error spawning player:", err)
+		return
+	}
+	players.Join(conn, spawn)
+	defer players.Leave(conn)
+
 	timeoutSeconds := 60 * time.Second
 	timeout := make(chan uint8, 1)
 	const StopTimeout uint8 = 0
@@ -95,21 +178,45 @@ func ServeNetConn(conn net.Conn) {
 
 	const MaxMsgSize int = 4 * 1024
 
+	done := make(chan struct{})
+	defer close(done)
+
 	go func() {
-		msg := make([]byte, MaxMsgSize)
+		reader := network.NewReader(conn, MaxMsgSize)
+		report := func(res uint8) bool {
+			select {
+			case timeout <- res:
+				return true
+			case <-done:
+				return false
+			}
+		}
 
 		for {
-			n, err := conn.Read(msg)
-
+			msg, err := reader.Read()
 			if err != nil {
 				log.Println("read error:", err)
-				timeout <- StopTimeout
+				report(StopTimeout)
 				return
 			}
 
-			timeout <- ContTimeout
+			if !report(ContTimeout) {
+				return
+			}
 
-			log.Println("message:", msg[:n])
+			switch mmo.MessageType(msg.Type) {
+			case mmo.InputMessage:
+				input := physics.SequencedInput{}
+				err := input.UnmarshalBinary(msg.Payload)
+				if err != nil {
+					log.Println("rejected input:", err)
+					continue
+				}
+				players.Input(conn, input)
+			case mmo.PingMessage:
+			default:
+				log.Println("unknown message type:", msg.Type)
+			}
 		}
 	}()
 
@@ -124,6 +231,34 @@ ExitTimeout:
 		case <-time.After(timeoutSeconds):
 			log.Println("User timed out!")
 			break ExitTimeout
+		case <-dropped:
+			log.Println("Client dropped")
+			break ExitTimeout
 		}
 	}
 }
+
+// sendWorld sends the world as it is now, for the client to build its own copy from.
+func sendWorld(conn net.Conn, world mmo.WorldState, clients *clientList) error {
+	msg, err := mmo.EncodeMessage(mmo.WorldMessage, world)
+	if err != nil {
+		return err
+	}
+	clients.Send(conn, msg)
+	return nil
+}
+
+// sendSpawn picks where the joining player starts and tells their client.
+func sendSpawn(conn net.Conn, spawner *mmo.Spawner, region string, clients *clientList) (physics.Transform, error) {
+	spawn, err := spawner.Spawn(region)
+	if err != nil {
+		return spawn, err
+	}
+	msg, err := mmo.EncodeMessage(mmo.SpawnMessage, spawn)
+	if err != nil {
+		return spawn, err
+	}
+	log.Println("spawning", conn.RemoteAddr(), "at", spawn)
+	clients.Send(conn, msg)
+	return spawn, nil
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding"
+	mmo "gommo"
+	"gommo/engine/ecs"
+	"gommo/engine/physics"
+	"log"
+	"net"
+	"time"
+)
+
+const playerEventBuffer = 1024
+
+// Clients send one input a frame, at the game's 60 frames a second, so players are only
+// allowed to move that often.
+// A few steps can be banked to absorb inputs arriving bunched up, anything queued beyond
+// that is dropped.
+const (
+	inputInterval    = 16 * time.Millisecond
+	inputBurst       = 4
+	maxPendingInputs = 8
+)
+
+// playerEvent is something a connection did. Connections run on their own goroutines, so
+// they queue events for the game loop instead of touching the engine themselves.
+type playerEvent struct {
+	conn  net.Conn
+	kind  playerEventKind
+	spawn physics.Transform
+	input physics.SequencedInput
+}
+
+type playerEventKind uint8
+
+const (
+	playerJoined playerEventKind = iota
+	playerInput
+	playerLeft
+)
+
+type player struct {
+	id      ecs.Id
+	ack     uint32
+	pending []physics.SequencedInput
+	budget  time.Duration
+}
+
+// playerList gives every connection an entity, and moves it by the inputs its client sends,
+// no faster than one every inputInterval. Every other client is told where it moved, so
+// they can draw it.
+type playerList struct {
+	engine   *ecs.Engine
+	clients  *clientList
+	movement physics.Movement
+	events   chan playerEvent
+
+	// Only used on the game loop
+	players map[net.Conn]*player
+}
+
+func newPlayerList(engine *ecs.Engine, clients *clientList, movement physics.Movement) *playerList {
+	return &playerList{
+		engine:   engine,
+		clients:  clients,
+		movement: movement,
+		events:   make(chan playerEvent, playerEventBuffer),
+		players:  make(map[net.Conn]*player),
+	}
+}
+
+func (list *playerList) Join(conn net.Conn, spawn physics.Transform) {
+	list.events <- playerEvent{conn: conn, kind: playerJoined, spawn: spawn}
+}
+
+func (list *playerList) Input(conn net.Conn, input physics.SequencedInput) {
+	list.events <- playerEvent{conn: conn, kind: playerInput, input: input}
+}
+
+func (list *playerList) Leave(conn net.Conn) {
+	list.events <- playerEvent{conn: conn, kind: playerLeft}
+}
+
+// Update handles every queued event, then moves each player by as many of its inputs as
+// the time since the last update allows. It must run on the game loop.
+func (list *playerList) Update(dt time.Duration) {
+	for done := false; !done; {
+		select {
+		case event := <-list.events:
+			list.handle(event)
+		default:
+			done = true
+		}
+	}
+
+	for conn, p := range list.players {
+		p.budget += dt
+		if p.budget > inputBurst*inputInterval {
+			p.budget = inputBurst * inputInterval
+		}
+		for len(p.pending) > 0 && p.budget >= inputInterval {
+			p.budget -= inputInterval
+			list.apply(conn, p, p.pending[0])
+			p.pending = p.pending[1:]
+		}
+	}
+}
+
+func (list *playerList) handle(event playerEvent) {
+	switch event.kind {
+	case playerJoined:
+		// Show the newcomer everyone already here
+		for _, p := range list.players {
+			transform := physics.Transform{}
+			if ecs.Read(list.engine, p.id, &transform) {
+				list.send(event.conn, mmo.EntityTransformMessage, mmo.EntityTransform{Id: p.id, Transform: transform})
+			}
+		}
+
+		id := list.engine.NewId()
+		ecs.Write(list.engine, id, event.spawn)
+		ecs.Write(list.engine, id, mmo.KeepLoaded{Radius: mmo.DefaultLoadRadius})
+		list.players[event.conn] = &player{id: id}
+		list.broadcast(event.conn, mmo.EntityTransformMessage, mmo.EntityTransform{Id: id, Transform: event.spawn})
+	case playerInput:
+		p, ok := list.players[event.conn]
+		if !ok || len(p.pending) >= maxPendingInputs {
+			return
+		}
+		// Inputs are applied at most once, in order
+		last := p.ack
+		if len(p.pending) > 0 {
+			last = p.pending[len(p.pending)-1].Sequence
+		}
+		if event.input.Sequence <= last {
+			return
+		}
+		p.pending = append(p.pending, event.input)
+	case playerLeft:
+		p, ok := list.players[event.conn]
+		if !ok {
+			return
+		}
+		ecs.Delete(list.engine, p.id)
+		delete(list.players, event.conn)
+		list.broadcast(event.conn, mmo.EntityRemovedMessage, mmo.EntityRemoval{Id: p.id})
+	}
+}
+
+// apply moves a player by one input and tells its client, and everyone else, where it is now.
+func (list *playerList) apply(conn net.Conn, p *player, input physics.SequencedInput) {
+	transform := physics.Transform{}
+	if !ecs.Read(list.engine, p.id, &transform) {
+		return
+	}
+	transform = list.movement.Step(transform, input.Input)
+	ecs.Write(list.engine, p.id, transform)
+	p.ack = input.Sequence
+
+	list.send(conn, mmo.InputAckMessage, physics.InputAck{Sequence: p.ack, Transform: transform})
+	list.broadcast(conn, mmo.EntityTransformMessage, mmo.EntityTransform{Id: p.id, Transform: transform})
+}
+
+func (list *playerList) send(conn net.Conn, msgType mmo.MessageType, payload encoding.BinaryMarshaler) {
+	msg, err := mmo.EncodeMessage(msgType, payload)
+	if err != nil {
+		log.Println("error encoding message:", err)
+		return
+	}
+	list.clients.Send(conn, msg)
+}
+
+// broadcast sends to everyone but the player the message is about.
+func (list *playerList) broadcast(about net.Conn, msgType mmo.MessageType, payload encoding.BinaryMarshaler) {
+	msg, err := mmo.EncodeMessage(msgType, payload)
+	if err != nil {
+		log.Println("error encoding message:", err)
+		return
+	}
+	list.clients.BroadcastExcept(about, msg)
+}
@@ -0,0 +1,138 @@
+package mmo
+
+import (
+	"fmt"
+	"gommo/engine/asset"
+	"gommo/engine/proceduralgeneration"
+	"gommo/engine/tilemap"
+)
+
+const worldJson = "assets/world.json"
+
+// WorldConfig holds everything the generator needs to build a world, so maps can be
+// tuned without recompiling. Heights come from Terrain when it is set, and otherwise from
+// Octaves raised to Exponent. Terrain should produce heights in [0, 1].
+type WorldConfig struct {
+	Seed           int64                           `json:"seed"`
+	MapSize        int                             `json:"mapSize"`
+	TileSize       int                             `json:"tileSize"`
+	Terrain        *proceduralgeneration.NoiseSpec `json:"terrain"`
+	Octaves        []proceduralgeneration.Octave   `json:"octaves"`
+	Exponent       float64                         `json:"exponent"`
+	IslandExponent float64                         `json:"islandExponent"`
+	WaterLevel     float64                         `json:"waterLevel"`
+	SandLevel      float64                         `json:"sandLevel"`
+	// When WaterPercent is set, WaterLevel and SandLevel are instead picked from a sample of
+	// the heights, so that these percentages of the map are water and sand.
+	WaterPercent float64 `json:"waterPercent"`
+	SandPercent  float64 `json:"sandPercent"`
+	// Players spawn in the first region unless they ask for another by name.
+	SpawnRegions []SpawnRegion `json:"spawnRegions"`
+	Towns        TownConfig    `json:"towns"`
+	// Chunked worlds are generated a chunk at a time around players instead of all at once,
+	// see CreateChunkedTilemap for what they leave out.
+	Chunked bool `json:"chunked"`
+}
+
+// TownConfig controls the settlements placed on flat land near water. Towns are kept at
+// least Spacing tiles apart and never overlap, so a map may get fewer than Count of them.
+type TownConfig struct {
+	Count   int     `json:"count"`
+	Spacing float64 `json:"spacing"`
+}
+
+// DefaultWorldConfig is the world that ships with the game.
+var DefaultWorldConfig = loadDefaultWorldConfig()
+
+func loadDefaultWorldConfig() WorldConfig {
+	config := WorldConfig{}
+	err := asset.NewLoad(assets).Json(worldJson, &config)
+	if err != nil {
+		panic(err)
+	}
+	err = config.Validate()
+	if err != nil {
+		panic(err)
+	}
+	return config
+}
+
+// LoadWorldConfig reads a config from path. Settings it leaves out keep the values of
+// DefaultWorldConfig.
+func LoadWorldConfig(load *asset.Load, path string) (WorldConfig, error) {
+	config := DefaultWorldConfig
+	config.Octaves = nil
+	config.SpawnRegions = nil
+	err := load.Json(path, &config)
+	if err != nil {
+		return config, err
+	}
+	if config.Octaves == nil {
+		config.Octaves = DefaultWorldConfig.Octaves
+	}
+	if config.SpawnRegions == nil {
+		config.SpawnRegions = DefaultWorldConfig.SpawnRegions
+	}
+	return config, config.Validate()
+}
+
+func (config WorldConfig) Validate() error {
+	if config.MapSize <= 0 {
+		return fmt.Errorf("world config: mapSize must be positive, got %d", config.MapSize)
+	}
+	if config.TileSize <= 0 {
+		return fmt.Errorf("world config: tileSize must be positive, got %d", config.TileSize)
+	}
+	if config.Terrain != nil {
+		_, err := config.Terrain.Build(config.Seed)
+		if err != nil {
+			return fmt.Errorf("world config: terrain: %w", err)
+		}
+	} else if len(config.Octaves) == 0 {
+		return fmt.Errorf("world config: at least one octave is required")
+	}
+	if config.Exponent <= 0 || config.IslandExponent <= 0 {
+		return fmt.Errorf("world config: exponents must be positive")
+	}
+	if config.WaterPercent < 0 || config.SandPercent < 0 || config.WaterPercent+config.SandPercent > 100 {
+		return fmt.Errorf("world config: waterPercent %v and sandPercent %v must add up to between 0 and 100", config.WaterPercent, config.SandPercent)
+	}
+	if config.WaterLevel > config.SandLevel {
+		return fmt.Errorf("world config: waterLevel %v is above sandLevel %v", config.WaterLevel, config.SandLevel)
+	}
+	if config.Towns.Count < 0 || config.Towns.Spacing < 0 {
+		return fmt.Errorf("world config: town count and spacing can't be negative")
+	}
+	names := make(map[string]bool)
+	for _, region := range config.SpawnRegions {
+		if region.Name == "" || names[region.Name] {
+			return fmt.Errorf("world config: spawn regions need unique names, got %q", region.Name)
+		}
+		names[region.Name] = true
+		if region.X < 0 || region.X > 1 || region.Y < 0 || region.Y > 1 || region.Spread < 0 {
+			return fmt.Errorf("world config: spawn region %q must lie within the map and have a positive spread", region.Name)
+		}
+	}
+	return nil
+}
+
+// terrain builds the noise heights are sampled from.
+func (config WorldConfig) terrain() proceduralgeneration.Noise {
+	if config.Terrain == nil {
+		return proceduralgeneration.NewNoiseMap(config.Seed, config.Octaves, config.Exponent)
+	}
+	terrain, err := config.Terrain.Build(config.Seed)
+	if err != nil {
+		panic(err)
+	}
+	return terrain
+}
+
+func (config WorldConfig) terrainType(height float64) tilemap.TileType {
+	if height < config.WaterLevel {
+		return WaterTile
+	} else if height < config.SandLevel {
+		return SandTile
+	}
+	return GrassTile
+}
@@ -0,0 +1,162 @@
+package mmo
+
+import (
+	"errors"
+	"fmt"
+	"gommo/engine/dungeon"
+	"gommo/engine/ecs"
+	"gommo/engine/physics"
+	"gommo/engine/random"
+	"gommo/engine/tilemap"
+	"math/rand"
+	"sort"
+)
+
+type DungeonKind uint8
+
+const (
+	CaveDungeon DungeonKind = iota
+	RoomDungeon
+)
+
+const (
+	// One monster spawn for this many floor tiles
+	dungeonSpawnArea = 80
+	dungeonLootArea  = 300
+	// Markers keep this many steps away from the entrance and from each other
+	dungeonSafeDistance = 10
+	dungeonMarkerSpread = 5
+	// Loot goes in nooks, floor tiles with at least this many of their 8 neighbours wall
+	dungeonNookWalls = 5
+	// Layouts tried before giving up, in case the cellular automaton fills a cave with wall
+	dungeonAttempts = 8
+)
+
+var (
+	ErrNoDungeonFloor  = errors.New("dungeon generation left no floor")
+	ErrDungeonTooSmall = fmt.Errorf("dungeons must be at least %d tiles wide and high", minDungeonSize)
+)
+
+// minDungeonSize fits the smallest room inside the wall around the dungeon.
+var minDungeonSize = 2 + dungeon.DefaultBSPSettings.MinRoomSize
+
+var dungeonTiles = dungeon.Tiles{Floor: StoneTile, Wall: WallTile}
+
+// Dungeon is an instanced map, generated separately for each party that enters it. The
+// entrance and exit are as far apart as the layout allows.
+type Dungeon struct {
+	Tilemap  *tilemap.Tilemap
+	Entrance tilemap.Point
+	Exit     tilemap.Point
+	Spawns   []tilemap.Point
+	Loot     []tilemap.Point
+}
+
+// CreateDungeon generates a dungeon. The same kind, streams and size always give the same
+// dungeon, so a party can be sent just the seed. Layouts without any floor are thrown away
+// and the next one drawn from the same stream is tried instead.
+func CreateDungeon(kind DungeonKind, streams random.Streams, width int, height int, tileSize int) (*Dungeon, error) {
+	if width < minDungeonSize || height < minDungeonSize {
+		return nil, ErrDungeonTooSmall
+	}
+	rng := streams.Stream(random.Key{Purpose: "dungeon/layout"})
+
+	for attempt := 0; attempt < dungeonAttempts; attempt++ {
+		tmap, start, ok := layoutDungeon(kind, rng, width, height, tileSize)
+		if !ok {
+			continue
+		}
+
+		// The farthest tile from anywhere is one end of the longest walk through the dungeon
+		entrance, _ := dungeon.Farthest(tmap, dungeonTiles, start)
+		exit, _ := dungeon.Farthest(tmap, dungeonTiles, entrance)
+		d := &Dungeon{Tilemap: tmap, Entrance: entrance, Exit: exit}
+		d.placeMarkers(streams.Stream(random.Key{Purpose: "dungeon/markers"}))
+		return d, nil
+	}
+	return nil, ErrNoDungeonFloor
+}
+
+// layoutDungeon generates the tiles of a dungeon and picks a floor tile to measure it from.
+func layoutDungeon(kind DungeonKind, rng *rand.Rand, width int, height int, tileSize int) (*tilemap.Tilemap, tilemap.Point, bool) {
+	switch kind {
+	case RoomDungeon:
+		tmap, rooms := dungeon.BSP(rng, width, height, tileSize, dungeonTiles, dungeon.DefaultBSPSettings)
+		if len(rooms) == 0 {
+			return nil, tilemap.Point{}, false
+		}
+		start := rooms[0].Center()
+		tile, ok := tmap.Get(start.X, start.Y)
+		return tmap, start, ok && tile.Type == StoneTile
+	default:
+		tmap := dungeon.Cave(rng, width, height, tileSize, dungeonTiles, dungeon.DefaultCaveSettings)
+		regions := tmap.LabelRegions(func(tile tilemap.Tile) bool { return tile.Type == StoneTile })
+		main, ok := regions.Largest()
+		return tmap, main.Start, ok
+	}
+}
+
+func (d *Dungeon) placeMarkers(rng *rand.Rand) {
+	distances := dungeon.Distances(d.Tilemap, dungeonTiles, d.Entrance)
+	floor := make([]tilemap.Point, 0, len(distances))
+	for p, distance := range distances {
+		if distance >= dungeonSafeDistance && p != d.Exit {
+			floor = append(floor, p)
+		}
+	}
+	// Map iteration order is random, sort so the rng picks the same tiles every time
+	sort.Slice(floor, func(i, j int) bool {
+		return floor[i].X < floor[j].X || (floor[i].X == floor[j].X && floor[i].Y < floor[j].Y)
+	})
+	rng.Shuffle(len(floor), func(i, j int) { floor[i], floor[j] = floor[j], floor[i] })
+
+	taken := []tilemap.Point{}
+	spread := func(p tilemap.Point) bool {
+		for _, other := range taken {
+			dx, dy := p.X-other.X, p.Y-other.Y
+			if dx*dx+dy*dy < dungeonMarkerSpread*dungeonMarkerSpread {
+				return false
+			}
+		}
+		return true
+	}
+
+	for _, p := range floor {
+		if len(d.Loot) >= len(distances)/dungeonLootArea+1 {
+			break
+		}
+		if dungeon.WallNeighbors(d.Tilemap, dungeonTiles, p.X, p.Y) >= dungeonNookWalls && spread(p) {
+			d.Loot = append(d.Loot, p)
+			taken = append(taken, p)
+		}
+	}
+	for _, p := range floor {
+		if len(d.Spawns) >= len(distances)/dungeonSpawnArea {
+			break
+		}
+		if spread(p) {
+			d.Spawns = append(d.Spawns, p)
+			taken = append(taken, p)
+		}
+	}
+}
+
+// CreateMarkers creates a SpawnMarker entity for the entrance, exit, monster spawns and
+// loot of the dungeon, like the objects of a map imported from Tiled.
+func (d *Dungeon) CreateMarkers(engine *ecs.Engine) {
+	add := func(p tilemap.Point, markerType string) {
+		x, y := d.Tilemap.TileToWorld(p.X, p.Y)
+		id := engine.NewId()
+		ecs.Write(engine, id, physics.Transform{X: x, Y: y})
+		ecs.Write(engine, id, SpawnMarker{Name: markerType, Type: markerType})
+	}
+
+	add(d.Entrance, "entrance")
+	add(d.Exit, "exit")
+	for _, p := range d.Spawns {
+		add(p, "spawn")
+	}
+	for _, p := range d.Loot {
+		add(p, "loot")
+	}
+}
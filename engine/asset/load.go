@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"github.com/faiface/pixel"
 	"github.com/unitoftime/packer"
+	"gommo/engine/tilemap"
 	"image"
 	_ "image/png"
 	"io/fs"
@@ -93,3 +94,13 @@ func (load *Load) Spritesheet(path string) (*Spritesheet, error) {
 
 	return NewSpritesheet(pic, lookup), nil
 }
+
+func (load *Load) TileRegistry(path string) (*tilemap.Registry, error) {
+	definitions := []tilemap.TileDefinition{}
+	err := load.Json(path, &definitions)
+	if err != nil {
+		return nil, err
+	}
+
+	return tilemap.NewRegistry(definitions)
+}
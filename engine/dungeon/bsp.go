@@ -0,0 +1,144 @@
+package dungeon
+
+import (
+	"gommo/engine/tilemap"
+	"math/rand"
+)
+
+// Room is a rectangle of floor tiles, inclusive of its min and max tiles.
+type Room struct {
+	Min, Max tilemap.Point
+}
+
+func (room Room) Center() tilemap.Point {
+	return tilemap.Point{X: (room.Min.X + room.Max.X) / 2, Y: (room.Min.Y + room.Max.Y) / 2}
+}
+
+// Empty reports whether the room has no tiles, as placed in a leaf too small for one.
+func (room Room) Empty() bool {
+	return room.Max.X < room.Min.X || room.Max.Y < room.Min.Y
+}
+
+func (room Room) Contains(p tilemap.Point) bool {
+	return p.X >= room.Min.X && p.X <= room.Max.X && p.Y >= room.Min.Y && p.Y <= room.Max.Y
+}
+
+// BSPSettings control how the map is split. Areas are split until they are smaller than
+// twice MinLeafSize, and each leaf holds a room of at least MinRoomSize tiles per side.
+type BSPSettings struct {
+	MinLeafSize int
+	MinRoomSize int
+}
+
+var DefaultBSPSettings = BSPSettings{
+	MinLeafSize: 10,
+	MinRoomSize: 4,
+}
+
+type leaf struct {
+	min, max tilemap.Point
+}
+
+// BSP generates rooms by recursively splitting the map, then joins sibling rooms with
+// corridors from the bottom of the tree up, so every room is reachable.
+func BSP(rng *rand.Rand, width int, height int, tileSize int, tiles Tiles, settings BSPSettings) (*tilemap.Tilemap, []Room) {
+	tmap := filled(width, height, tileSize, tiles.Wall)
+	rooms := []Room{}
+
+	// split returns a room of the subtree to connect its sibling to.
+	var split func(area leaf) Room
+	split = func(area leaf) Room {
+		w, h := area.max.X-area.min.X+1, area.max.Y-area.min.Y+1
+		horizontal := w < h || (w == h && rng.Intn(2) == 0)
+		size := w
+		if horizontal {
+			size = h
+		}
+
+		if size < 2*settings.MinLeafSize {
+			room := placeRoom(rng, area, settings.MinRoomSize)
+			// Leaves too small for any room are left as wall, their corridors still pass through
+			if !room.Empty() && tmap.Fill(tilemap.GroundLayer, room.Min.X, room.Min.Y, room.Max.X, room.Max.Y, tilemap.Tile{Type: tiles.Floor}) {
+				rooms = append(rooms, room)
+			}
+			return room
+		}
+
+		at := settings.MinLeafSize + rng.Intn(size-2*settings.MinLeafSize+1)
+		a, b := area, area
+		if horizontal {
+			a.max.Y = area.min.Y + at - 1
+			b.min.Y = area.min.Y + at
+		} else {
+			a.max.X = area.min.X + at - 1
+			b.min.X = area.min.X + at
+		}
+		roomA, roomB := split(a), split(b)
+		carve(tmap, tiles, corridor(rng, roomA.Center(), roomB.Center()))
+		if rng.Intn(2) == 0 {
+			return roomA
+		}
+		return roomB
+	}
+
+	// Leave a border of wall around the map
+	split(leaf{min: tilemap.Point{X: 1, Y: 1}, max: tilemap.Point{X: width - 2, Y: height - 2}})
+	return tmap, rooms
+}
+
+// placeRoom picks a room inside a leaf. Leaves narrower than minSize get a room as wide as
+// they allow, which is empty if the leaf has no room for floor at all.
+func placeRoom(rng *rand.Rand, area leaf, minSize int) Room {
+	// Keep a tile of wall between rooms in neighbouring leaves
+	maxW, maxH := area.max.X-area.min.X, area.max.Y-area.min.Y
+	minW, minH := minInt(minSize, maxW), minInt(minSize, maxH)
+	w := minW + rng.Intn(maxInt(maxW-minW, 0)+1)
+	h := minH + rng.Intn(maxInt(maxH-minH, 0)+1)
+	x := area.min.X + rng.Intn(maxInt(maxW-w, 0)+1)
+	y := area.min.Y + rng.Intn(maxInt(maxH-h, 0)+1)
+	return Room{Min: tilemap.Point{X: x, Y: y}, Max: tilemap.Point{X: x + w - 1, Y: y + h - 1}}
+}
+
+// corridor returns an L shaped path between two points, turning at a random corner.
+func corridor(rng *rand.Rand, from tilemap.Point, to tilemap.Point) []tilemap.Point {
+	corner := tilemap.Point{X: to.X, Y: from.Y}
+	if rng.Intn(2) == 0 {
+		corner = tilemap.Point{X: from.X, Y: to.Y}
+	}
+	path := line(from, corner)
+	return append(path, line(corner, to)...)
+}
+
+func line(from tilemap.Point, to tilemap.Point) []tilemap.Point {
+	path := []tilemap.Point{from}
+	for p := from; p != to; {
+		p.X += sign(to.X - p.X)
+		p.Y += sign(to.Y - p.Y)
+		path = append(path, p)
+	}
+	return path
+}
+
+func sign(v int) int {
+	if v < 0 {
+		return -1
+	}
+	if v > 0 {
+		return 1
+	}
+	return 0
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
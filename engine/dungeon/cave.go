@@ -0,0 +1,61 @@
+package dungeon
+
+import (
+	"gommo/engine/tilemap"
+	"math/rand"
+)
+
+// CaveSettings control the cellular automaton that smooths random noise into caves. Each
+// step a tile becomes wall if at least BirthLimit of its 8 neighbours are wall, stays wall
+// if at least SurviveLimit are, and otherwise becomes floor.
+type CaveSettings struct {
+	WallChance    float64
+	Steps         int
+	BirthLimit    int
+	SurviveLimit  int
+	MinRegionSize int
+}
+
+var DefaultCaveSettings = CaveSettings{
+	WallChance:    0.45,
+	Steps:         5,
+	BirthLimit:    5,
+	SurviveLimit:  4,
+	MinRegionSize: 16,
+}
+
+// Cave generates a connected cave surrounded by solid wall.
+func Cave(rng *rand.Rand, width int, height int, tileSize int, tiles Tiles, settings CaveSettings) *tilemap.Tilemap {
+	tmap := filled(width, height, tileSize, tiles.Wall)
+	cells := []tilemap.Cell{}
+	for x := 1; x < width-1; x++ {
+		for y := 1; y < height-1; y++ {
+			if rng.Float64() >= settings.WallChance {
+				cells = append(cells, tilemap.Cell{Layer: tilemap.GroundLayer, X: x, Y: y, Tile: tilemap.Tile{Type: tiles.Floor}})
+			}
+		}
+	}
+	tmap.Apply(cells)
+
+	for step := 0; step < settings.Steps; step++ {
+		cells = cells[:0]
+		for x := 1; x < width-1; x++ {
+			for y := 1; y < height-1; y++ {
+				walls := WallNeighbors(tmap, tiles, x, y)
+				tile, _ := tmap.Get(x, y)
+				wall := walls >= settings.BirthLimit || (!tiles.isFloor(tile) && walls >= settings.SurviveLimit)
+
+				next := tiles.Floor
+				if wall {
+					next = tiles.Wall
+				}
+				cells = append(cells, tilemap.Cell{Layer: tilemap.GroundLayer, X: x, Y: y, Tile: tilemap.Tile{Type: next}})
+			}
+		}
+		// Apply every change at once so each step only sees the previous one
+		tmap.Apply(cells)
+	}
+
+	Connect(tmap, tiles, settings.MinRegionSize)
+	return tmap
+}
@@ -0,0 +1,149 @@
+package dungeon
+
+import (
+	"gommo/engine/tilemap"
+)
+
+// Tiles are the ground tiles generators use for open floor and solid wall.
+type Tiles struct {
+	Floor tilemap.TileType
+	Wall  tilemap.TileType
+}
+
+func (tiles Tiles) isFloor(tile tilemap.Tile) bool {
+	return tile.Type == tiles.Floor
+}
+
+// filled returns a tilemap of solid wall.
+func filled(width int, height int, tileSize int, tile tilemap.TileType) *tilemap.Tilemap {
+	cells := make([][]tilemap.Tile, width)
+	for x := range cells {
+		cells[x] = make([]tilemap.Tile, height)
+		for y := range cells[x] {
+			cells[x][y] = tilemap.Tile{Type: tile}
+		}
+	}
+	return tilemap.New(cells, tileSize)
+}
+
+// Connect joins every area of floor into one. Areas smaller than minSize are filled in,
+// and the rest are joined to the largest area by carving the shortest corridor between them.
+func Connect(tmap *tilemap.Tilemap, tiles Tiles, minSize int) {
+	regions := tmap.LabelRegions(tiles.isFloor)
+	cells := []tilemap.Cell{}
+	for _, region := range regions.Regions {
+		if region.Size >= minSize {
+			continue
+		}
+		for _, p := range regions.Points(region.Id) {
+			cells = append(cells, tilemap.Cell{Layer: tilemap.GroundLayer, X: p.X, Y: p.Y, Tile: tilemap.Tile{Type: tiles.Wall}})
+		}
+	}
+	tmap.Apply(cells)
+
+	for {
+		regions = tmap.LabelRegions(tiles.isFloor)
+		main, ok := regions.Largest()
+		if !ok || len(regions.Regions) == 1 {
+			return
+		}
+		carve(tmap, tiles, corridorFrom(tmap, regions, main.Id))
+	}
+}
+
+// corridorFrom searches outwards from every tile of the region at once, through walls, and
+// returns the wall tiles on the way to the closest floor tile of another region.
+func corridorFrom(tmap *tilemap.Tilemap, regions *tilemap.Regions, id int) []tilemap.Point {
+	parent := make(map[tilemap.Point]tilemap.Point)
+	queue := []tilemap.Point{}
+	for x := 0; x < tmap.Width(); x++ {
+		for y := 0; y < tmap.Height(); y++ {
+			if regions.Label(x, y) == id {
+				p := tilemap.Point{X: x, Y: y}
+				parent[p] = p
+				queue = append(queue, p)
+			}
+		}
+	}
+
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		for _, n := range tmap.Neighbors4(p) {
+			if _, seen := parent[n]; seen {
+				continue
+			}
+			label := regions.Label(n.X, n.Y)
+			// Keep the border solid
+			if label == 0 && (n.X == 0 || n.Y == 0 || n.X == tmap.Width()-1 || n.Y == tmap.Height()-1) {
+				continue
+			}
+			parent[n] = p
+			if label != 0 && label != id {
+				path := []tilemap.Point{}
+				for step := p; parent[step] != step; step = parent[step] {
+					path = append(path, step)
+				}
+				return path
+			}
+			queue = append(queue, n)
+		}
+	}
+	return nil
+}
+
+func carve(tmap *tilemap.Tilemap, tiles Tiles, path []tilemap.Point) {
+	cells := make([]tilemap.Cell, len(path))
+	for i, p := range path {
+		cells[i] = tilemap.Cell{Layer: tilemap.GroundLayer, X: p.X, Y: p.Y, Tile: tilemap.Tile{Type: tiles.Floor}}
+	}
+	tmap.Apply(cells)
+}
+
+// Distances returns how many steps every floor tile is from start, walking between
+// neighbouring floor tiles. Unreachable tiles are left out.
+func Distances(tmap *tilemap.Tilemap, tiles Tiles, start tilemap.Point) map[tilemap.Point]int {
+	distances := map[tilemap.Point]int{start: 0}
+	queue := []tilemap.Point{start}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		for _, n := range tmap.Neighbors4(p) {
+			tile, _ := tmap.Get(n.X, n.Y)
+			if _, seen := distances[n]; seen || !tiles.isFloor(tile) {
+				continue
+			}
+			distances[n] = distances[p] + 1
+			queue = append(queue, n)
+		}
+	}
+	return distances
+}
+
+// Farthest returns the floor tile the most steps away from start, preferring the lowest x
+// then y on ties so the result is deterministic.
+func Farthest(tmap *tilemap.Tilemap, tiles Tiles, start tilemap.Point) (tilemap.Point, int) {
+	best, bestDistance := start, 0
+	for p, distance := range Distances(tmap, tiles, start) {
+		if distance > bestDistance || (distance == bestDistance && less(p, best)) {
+			best, bestDistance = p, distance
+		}
+	}
+	return best, bestDistance
+}
+
+func less(a tilemap.Point, b tilemap.Point) bool {
+	return a.X < b.X || (a.X == b.X && a.Y < b.Y)
+}
+
+// WallNeighbors counts the wall tiles around (x, y), counting the outside of the map as wall.
+func WallNeighbors(tmap *tilemap.Tilemap, tiles Tiles, x int, y int) int {
+	count := 0
+	for _, d := range tilemap.Directions8 {
+		tile, ok := tmap.Get(x+d.X, y+d.Y)
+		if !ok || !tiles.isFloor(tile) {
+			count++
+		}
+	}
+	return count
+}
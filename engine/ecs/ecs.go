@@ -27,14 +27,20 @@ func (BasicStorage *BasicStorage) Write(id Id, val interface{}) {
 	BasicStorage.list[id] = val
 }
 
+func (BasicStorage *BasicStorage) Delete(id Id) {
+	delete(BasicStorage.list, id)
+}
+
 type Engine struct {
 	reg       map[string]*BasicStorage
+	resources map[string]interface{}
 	idCounter Id
 }
 
 func NewEngine() *Engine {
 	return &Engine{
 		reg:       make(map[string]*BasicStorage),
+		resources: make(map[string]interface{}),
 		idCounter: 0,
 	}
 }
@@ -83,3 +89,23 @@ func Each(engine *Engine, val interface{}, f func(id Id, a interface{})) {
 		f(id, a)
 	}
 }
+
+func Delete(engine *Engine, id Id) {
+	for _, storage := range engine.reg {
+		storage.Delete(id)
+	}
+}
+
+// WriteResource stores a value that belongs to the whole engine instead of an entity, like
+// a shared service. There is at most one resource of each type.
+func WriteResource(engine *Engine, val interface{}) {
+	engine.resources[name(val)] = val
+}
+
+func ReadResource(engine *Engine, val Component) bool {
+	newVal, ok := engine.resources[name(val)]
+	if ok {
+		val.ComponentSet(newVal)
+	}
+	return ok
+}
@@ -0,0 +1,66 @@
+package network
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// DefaultMaxMessageSize is the largest message a Reader accepts unless told otherwise.
+const DefaultMaxMessageSize = 16 << 20
+
+var (
+	ErrEmptyMessage    = errors.New("message has no type")
+	ErrMessageTooLarge = errors.New("message too large")
+)
+
+// Message is a typed payload. On the wire it is a little endian uint32 holding the length
+// of the rest of the message, then the type byte and the payload, so messages can be read
+// back whole however the transport splits or joins them.
+type Message struct {
+	Type    uint8
+	Payload []byte
+}
+
+func (message Message) MarshalBinary() ([]byte, error) {
+	data := make([]byte, 5+len(message.Payload))
+	binary.LittleEndian.PutUint32(data, uint32(1+len(message.Payload)))
+	data[4] = message.Type
+	copy(data[5:], message.Payload)
+	return data, nil
+}
+
+// Reader reads framed messages from a stream. It is not safe for concurrent use.
+type Reader struct {
+	r       io.Reader
+	maxSize int
+}
+
+// NewReader reads messages from r, rejecting any longer than maxSize bytes.
+func NewReader(r io.Reader, maxSize int) *Reader {
+	return &Reader{r: r, maxSize: maxSize}
+}
+
+// Read blocks until a whole message has arrived.
+func (reader *Reader) Read() (Message, error) {
+	header := make([]byte, 4)
+	_, err := io.ReadFull(reader.r, header)
+	if err != nil {
+		return Message{}, err
+	}
+
+	length := binary.LittleEndian.Uint32(header)
+	if length == 0 {
+		return Message{}, ErrEmptyMessage
+	}
+	if uint64(length) > uint64(reader.maxSize) {
+		return Message{}, ErrMessageTooLarge
+	}
+
+	data := make([]byte, length)
+	_, err = io.ReadFull(reader.r, data)
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{Type: data[0], Payload: data[1:]}, nil
+}
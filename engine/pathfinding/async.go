@@ -0,0 +1,110 @@
+package pathfinding
+
+import (
+	"gommo/engine/ecs"
+	"gommo/engine/tilemap"
+	"sync"
+)
+
+type Request struct {
+	Id    ecs.Id
+	Start tilemap.Point
+	Goal  tilemap.Point
+}
+
+type Result struct {
+	Request
+	Path []tilemap.Point
+	Err  error
+}
+
+// Async runs path requests on worker goroutines so the simulation never waits on a search.
+type Async struct {
+	pathfinder *Pathfinder
+	requests   chan Request
+	results    chan Result
+	// Closed by Close, so workers blocked on a full results queue give up instead of leaking
+	done chan struct{}
+	wg   sync.WaitGroup
+
+	mu     sync.Mutex
+	closed bool
+}
+
+func NewAsync(pathfinder *Pathfinder, workers int, queueSize int) *Async {
+	async := &Async{
+		pathfinder: pathfinder,
+		requests:   make(chan Request, queueSize),
+		results:    make(chan Result, queueSize),
+		done:       make(chan struct{}),
+	}
+
+	async.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go async.work()
+	}
+	return async
+}
+
+func (async *Async) work() {
+	defer async.wg.Done()
+	for req := range async.requests {
+		path, err := async.pathfinder.Find(req.Start, req.Goal)
+		select {
+		case async.results <- Result{Request: req, Path: path, Err: err}:
+		case <-async.done:
+			return
+		}
+	}
+}
+
+// Submit queues a request, returning false if the queue is full or Close has been called.
+func (async *Async) Submit(req Request) bool {
+	async.mu.Lock()
+	defer async.mu.Unlock()
+	if async.closed {
+		return false
+	}
+
+	select {
+	case async.requests <- req:
+		return true
+	default:
+		return false
+	}
+}
+
+// Poll returns every result that has finished since the last call without blocking.
+func (async *Async) Poll() []Result {
+	results := []Result{}
+	for {
+		select {
+		case res, ok := <-async.results:
+			if !ok {
+				return results
+			}
+			results = append(results, res)
+		default:
+			return results
+		}
+	}
+}
+
+// Close stops the workers without waiting for anyone to poll, so requests still queued may
+// be dropped. Results that already finished stay available to Poll. It is safe to call more
+// than once.
+func (async *Async) Close() {
+	async.mu.Lock()
+	defer async.mu.Unlock()
+	if async.closed {
+		return
+	}
+	async.closed = true
+
+	close(async.requests)
+	close(async.done)
+	go func() {
+		async.wg.Wait()
+		close(async.results)
+	}()
+}
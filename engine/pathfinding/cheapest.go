@@ -0,0 +1,105 @@
+package pathfinding
+
+import (
+	"container/heap"
+	"gommo/engine/tilemap"
+)
+
+// StepCost returns the cost of moving from one tile to a 4-connected neighbour and whether
+// the move is allowed at all.
+type StepCost func(from, to tilemap.Point) (float64, bool)
+
+// Cheapest finds the least cost 4-connected path from start to goal on a width by height
+// grid, both included. Unlike Find it isn't limited to walkable tiles or a node budget, so
+// it suits world generation, like laying roads over the terrain. minCost is a lower bound
+// on the cost of any step and guides the search towards the goal.
+func Cheapest(width, height int, start, goal tilemap.Point, minCost float64, cost StepCost) ([]tilemap.Point, float64, error) {
+	inBounds := func(p tilemap.Point) bool {
+		return p.X >= 0 && p.X < width && p.Y >= 0 && p.Y < height
+	}
+	if !inBounds(start) || !inBounds(goal) {
+		return nil, 0, ErrNotWalkable
+	}
+
+	index := func(p tilemap.Point) int { return p.X*height + p.Y }
+	estimate := func(p tilemap.Point) float64 {
+		return float64(p.Manhattan(goal)) * minCost
+	}
+
+	g := make([]float64, width*height)
+	parent := make([]int32, width*height)
+	state := make([]uint8, width*height)
+	const (
+		unseen uint8 = iota
+		open
+		closed
+	)
+
+	queue := &gridQueue{}
+	g[index(start)] = 0
+	parent[index(start)] = -1
+	state[index(start)] = open
+	heap.Push(queue, gridNode{point: start, f: estimate(start)})
+
+	for queue.Len() > 0 {
+		current := heap.Pop(queue).(gridNode)
+		i := index(current.point)
+		if state[i] == closed {
+			continue
+		}
+		state[i] = closed
+
+		if current.point == goal {
+			path := []tilemap.Point{}
+			for j := int32(i); j != -1; j = parent[j] {
+				path = append(path, tilemap.Point{X: int(j) / height, Y: int(j) % height})
+			}
+			for a, b := 0, len(path)-1; a < b; a, b = a+1, b-1 {
+				path[a], path[b] = path[b], path[a]
+			}
+			return path, g[i], nil
+		}
+
+		for _, dir := range tilemap.Directions4 {
+			next := tilemap.Point{X: current.point.X + dir.X, Y: current.point.Y + dir.Y}
+			if !inBounds(next) {
+				continue
+			}
+			n := index(next)
+			if state[n] == closed {
+				continue
+			}
+			step, ok := cost(current.point, next)
+			if !ok {
+				continue
+			}
+			if state[n] == open && g[i]+step >= g[n] {
+				continue
+			}
+			g[n] = g[i] + step
+			parent[n] = int32(i)
+			state[n] = open
+			// Stale entries are skipped when popped instead of being fixed in place
+			heap.Push(queue, gridNode{point: next, f: g[n] + estimate(next)})
+		}
+	}
+	return nil, 0, ErrNoPath
+}
+
+type gridNode struct {
+	point tilemap.Point
+	f     float64
+}
+
+type gridQueue []gridNode
+
+func (q gridQueue) Len() int            { return len(q) }
+func (q gridQueue) Less(i, j int) bool  { return q[i].f < q[j].f }
+func (q gridQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *gridQueue) Push(x interface{}) { *q = append(*q, x.(gridNode)) }
+func (q *gridQueue) Pop() interface{} {
+	old := *q
+	n := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return n
+}
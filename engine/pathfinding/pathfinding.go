@@ -0,0 +1,226 @@
+package pathfinding
+
+import (
+	"container/heap"
+	"errors"
+	"gommo/engine/tilemap"
+	"math"
+	"sync"
+)
+
+var (
+	ErrNotWalkable = errors.New("start or goal is not walkable")
+	ErrNoPath      = errors.New("no path found")
+	ErrNodeBudget  = errors.New("node budget exhausted")
+)
+
+const (
+	DefaultMaxNodes  = 10000
+	DefaultCacheSize = 1024
+)
+
+// CostFunc returns the cost of entering a ground tile and whether it can be entered at all.
+// Costs should be at least 1 so the heuristic never overestimates. Cells blocked on the
+// collision layer are never entered.
+type CostFunc func(tile tilemap.Tile) (float64, bool)
+
+type Pathfinder struct {
+	MaxNodes  int
+	tilemap   tilemap.Map
+	cost      CostFunc
+	cacheSize int
+
+	mu    sync.Mutex
+	cache map[cacheKey][]tilemap.Point
+	// Bumped by ClearCache, so searches that started before it don't store stale paths
+	generation uint64
+}
+
+type cacheKey struct {
+	start, goal tilemap.Point
+}
+
+func New(tmap tilemap.Map, cost CostFunc) *Pathfinder {
+	pathfinder := &Pathfinder{
+		MaxNodes:  DefaultMaxNodes,
+		tilemap:   tmap,
+		cost:      cost,
+		cacheSize: DefaultCacheSize,
+		cache:     make(map[cacheKey][]tilemap.Point),
+	}
+	editable, ok := tmap.(tilemap.Editable)
+	if ok {
+		editable.OnChange(func(change tilemap.Change) {
+			pathfinder.ClearCache()
+		})
+	}
+	return pathfinder
+}
+
+func (pathfinder *Pathfinder) Walkable(p tilemap.Point) bool {
+	_, ok := pathfinder.tileCost(p)
+	return ok
+}
+
+func (pathfinder *Pathfinder) tileCost(p tilemap.Point) (float64, bool) {
+	tile, ok := pathfinder.tilemap.Get(p.X, p.Y)
+	if !ok || pathfinder.tilemap.Collides(p.X, p.Y) {
+		return 0, false
+	}
+	return pathfinder.cost(tile)
+}
+
+// Find returns a smoothed path from start to goal, both included. The path belongs to the
+// caller, so it is free to modify it.
+func (pathfinder *Pathfinder) Find(start, goal tilemap.Point) ([]tilemap.Point, error) {
+	key := cacheKey{start, goal}
+	pathfinder.mu.Lock()
+	path, ok := pathfinder.cache[key]
+	generation := pathfinder.generation
+	pathfinder.mu.Unlock()
+	if ok {
+		return copyPath(path), nil
+	}
+
+	path, err := pathfinder.search(start, goal)
+	if err != nil {
+		return nil, err
+	}
+	path = pathfinder.Smooth(path)
+
+	pathfinder.mu.Lock()
+	if generation == pathfinder.generation {
+		if len(pathfinder.cache) >= pathfinder.cacheSize {
+			pathfinder.cache = make(map[cacheKey][]tilemap.Point)
+		}
+		pathfinder.cache[key] = path
+	}
+	pathfinder.mu.Unlock()
+
+	return copyPath(path), nil
+}
+
+func copyPath(path []tilemap.Point) []tilemap.Point {
+	return append([]tilemap.Point(nil), path...)
+}
+
+func (pathfinder *Pathfinder) ClearCache() {
+	pathfinder.mu.Lock()
+	pathfinder.cache = make(map[cacheKey][]tilemap.Point)
+	pathfinder.generation++
+	pathfinder.mu.Unlock()
+}
+
+type node struct {
+	point  tilemap.Point
+	parent *node
+	g, f   float64
+	index  int
+	closed bool
+}
+
+type openList []*node
+
+func (l openList) Len() int           { return len(l) }
+func (l openList) Less(i, j int) bool { return l[i].f < l[j].f }
+func (l openList) Swap(i, j int) {
+	l[i], l[j] = l[j], l[i]
+	l[i].index = i
+	l[j].index = j
+}
+
+func (l *openList) Push(x interface{}) {
+	n := x.(*node)
+	n.index = len(*l)
+	*l = append(*l, n)
+}
+
+func (l *openList) Pop() interface{} {
+	old := *l
+	n := old[len(old)-1]
+	*l = old[:len(old)-1]
+	return n
+}
+
+func (pathfinder *Pathfinder) search(start, goal tilemap.Point) ([]tilemap.Point, error) {
+	if !pathfinder.Walkable(start) || !pathfinder.Walkable(goal) {
+		return nil, ErrNotWalkable
+	}
+
+	nodes := make(map[tilemap.Point]*node)
+	open := &openList{}
+
+	startNode := &node{point: start, f: heuristic(start, goal)}
+	nodes[start] = startNode
+	heap.Push(open, startNode)
+
+	expanded := 0
+	for open.Len() > 0 {
+		current := heap.Pop(open).(*node)
+		if current.point == goal {
+			return reconstruct(current), nil
+		}
+		current.closed = true
+
+		expanded++
+		if expanded > pathfinder.MaxNodes {
+			return nil, ErrNodeBudget
+		}
+
+		for _, dir := range tilemap.Directions8 {
+			next := tilemap.Point{X: current.point.X + dir.X, Y: current.point.Y + dir.Y}
+			cost, ok := pathfinder.tileCost(next)
+			if !ok {
+				continue
+			}
+
+			step := 1.0
+			if dir.X != 0 && dir.Y != 0 {
+				// Don't cut corners around blocked tiles
+				if !pathfinder.Walkable(tilemap.Point{X: current.point.X + dir.X, Y: current.point.Y}) ||
+					!pathfinder.Walkable(tilemap.Point{X: current.point.X, Y: current.point.Y + dir.Y}) {
+					continue
+				}
+				step = math.Sqrt2
+			}
+
+			g := current.g + step*cost
+			n, seen := nodes[next]
+			if seen && (n.closed || g >= n.g) {
+				continue
+			}
+			if !seen {
+				n = &node{point: next}
+				nodes[next] = n
+			}
+			n.parent = current
+			n.g = g
+			n.f = g + heuristic(next, goal)
+			if seen {
+				heap.Fix(open, n.index)
+			} else {
+				heap.Push(open, n)
+			}
+		}
+	}
+
+	return nil, ErrNoPath
+}
+
+// Octile distance
+func heuristic(a, b tilemap.Point) float64 {
+	dx := math.Abs(float64(a.X - b.X))
+	dy := math.Abs(float64(a.Y - b.Y))
+	return (dx + dy) + (math.Sqrt2-2)*math.Min(dx, dy)
+}
+
+func reconstruct(n *node) []tilemap.Point {
+	path := []tilemap.Point{}
+	for ; n != nil; n = n.parent {
+		path = append(path, n.point)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
@@ -0,0 +1,87 @@
+package pathfinding
+
+import "gommo/engine/tilemap"
+
+// Smooth removes intermediate waypoints whenever a straight line between two waypoints
+// crosses no tile more expensive than the tiles it replaces.
+func (pathfinder *Pathfinder) Smooth(path []tilemap.Point) []tilemap.Point {
+	if len(path) <= 2 {
+		return path
+	}
+
+	smoothed := []tilemap.Point{path[0]}
+	anchor := 0
+	for anchor < len(path)-1 {
+		maxCost := 0.0
+		next := anchor + 1
+		for i := anchor + 1; i < len(path); i++ {
+			cost, _ := pathfinder.tileCost(path[i])
+			if cost > maxCost {
+				maxCost = cost
+			}
+			if !pathfinder.clearLine(path[anchor], path[i], maxCost) {
+				break
+			}
+			next = i
+		}
+		smoothed = append(smoothed, path[next])
+		anchor = next
+	}
+	return smoothed
+}
+
+// clearLine walks every tile touched by the line between the centers of a and b.
+func (pathfinder *Pathfinder) clearLine(a, b tilemap.Point, maxCost float64) bool {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	stepX, stepY := sign(dx), sign(dy)
+	dx, dy = abs(dx), abs(dy)
+
+	x, y := a.X, a.Y
+	for ix, iy := 0, 0; ix < dx || iy < dy; {
+		decision := (1+2*ix)*dy - (1+2*iy)*dx
+		if decision == 0 {
+			// Line passes exactly through a corner, both sides must be clear
+			if !pathfinder.clearTile(tilemap.Point{X: x + stepX, Y: y}, maxCost) ||
+				!pathfinder.clearTile(tilemap.Point{X: x, Y: y + stepY}, maxCost) {
+				return false
+			}
+			x += stepX
+			y += stepY
+			ix++
+			iy++
+		} else if decision < 0 {
+			x += stepX
+			ix++
+		} else {
+			y += stepY
+			iy++
+		}
+
+		if !pathfinder.clearTile(tilemap.Point{X: x, Y: y}, maxCost) {
+			return false
+		}
+	}
+	return true
+}
+
+func (pathfinder *Pathfinder) clearTile(p tilemap.Point, maxCost float64) bool {
+	cost, ok := pathfinder.tileCost(p)
+	return ok && cost <= maxCost
+}
+
+func sign(v int) int {
+	if v < 0 {
+		return -1
+	}
+	if v > 0 {
+		return 1
+	}
+	return 0
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,91 @@
+package physics
+
+import (
+	"gommo/engine/ecs"
+	"gommo/engine/tilemap"
+	"math"
+)
+
+// Bounds is a world space rectangle, inclusive of the min edge and exclusive of the max edge.
+type Bounds struct {
+	MinX, MinY float64
+	MaxX, MaxY float64
+}
+
+// BoundsFromTilemap covers every tile of the map.
+func BoundsFromTilemap(tmap tilemap.Map) Bounds {
+	rect := tmap.WorldBounds()
+	return Bounds{MinX: rect.MinX, MinY: rect.MinY, MaxX: rect.MaxX, MaxY: rect.MaxY}
+}
+
+func (bounds Bounds) Contains(transform Transform) bool {
+	return transform.X >= bounds.MinX && transform.X < bounds.MaxX &&
+		transform.Y >= bounds.MinY && transform.Y < bounds.MaxY
+}
+
+func (bounds Bounds) Clamp(transform Transform) Transform {
+	transform.X = clamp(transform.X, bounds.MinX, bounds.MaxX)
+	transform.Y = clamp(transform.Y, bounds.MinY, bounds.MaxY)
+	return transform
+}
+
+func clamp(v, min, max float64) float64 {
+	if math.IsNaN(v) || v < min {
+		return min
+	}
+	if v >= max {
+		return math.Nextafter(max, min)
+	}
+	return v
+}
+
+func (bounds Bounds) Wrap(transform Transform) Transform {
+	transform.X = wrap(transform.X, bounds.MinX, bounds.MaxX)
+	transform.Y = wrap(transform.Y, bounds.MinY, bounds.MaxY)
+	return transform
+}
+
+func wrap(v, min, max float64) float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return min
+	}
+	v = math.Mod(v-min, max-min)
+	if v < 0 {
+		v += max - min
+	}
+	return v + min
+}
+
+type BoundsPolicy uint8
+
+const (
+	ClampToBounds BoundsPolicy = iota
+	WrapAroundBounds
+	KillOutOfBounds
+)
+
+func (policy *BoundsPolicy) ComponentSet(val interface{}) { *policy = val.(BoundsPolicy) }
+
+// EnforceBounds applies each entity's BoundsPolicy, clamping entities that don't have one.
+// Only entities with an Input can move, so static entities are never checked.
+func EnforceBounds(engine *ecs.Engine, bounds Bounds) {
+	ecs.Each(engine, Input{}, func(id ecs.Id, a interface{}) {
+		transform := Transform{}
+		ok := ecs.Read(engine, id, &transform)
+		if !ok || bounds.Contains(transform) {
+			return
+		}
+
+		policy := ClampToBounds
+		ecs.Read(engine, id, &policy)
+
+		switch policy {
+		case ClampToBounds:
+			ecs.Write(engine, id, bounds.Clamp(transform))
+		case WrapAroundBounds:
+			ecs.Write(engine, id, bounds.Wrap(transform))
+		case KillOutOfBounds:
+			ecs.Delete(engine, id)
+		}
+	})
+}
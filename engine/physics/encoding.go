@@ -0,0 +1,85 @@
+package physics
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+const TransformSize = 16
+
+func (transform Transform) MarshalBinary() ([]byte, error) {
+	data := make([]byte, TransformSize)
+	binary.LittleEndian.PutUint64(data[0:], math.Float64bits(transform.X))
+	binary.LittleEndian.PutUint64(data[8:], math.Float64bits(transform.Y))
+	return data, nil
+}
+
+func (transform *Transform) UnmarshalBinary(data []byte) error {
+	if len(data) != TransformSize {
+		return errors.New("invalid transform size")
+	}
+	transform.X = math.Float64frombits(binary.LittleEndian.Uint64(data[0:]))
+	transform.Y = math.Float64frombits(binary.LittleEndian.Uint64(data[8:]))
+	return nil
+}
+
+const (
+	SequencedInputSize = 5
+	InputAckSize       = 4 + TransformSize
+)
+
+const (
+	inputUp = 1 << iota
+	inputDown
+	inputLeft
+	inputRight
+)
+
+// MarshalBinary encodes the sequence number followed by a byte of direction flags.
+func (input SequencedInput) MarshalBinary() ([]byte, error) {
+	data := make([]byte, SequencedInputSize)
+	binary.LittleEndian.PutUint32(data[0:], input.Sequence)
+	flags := byte(0)
+	if input.Up {
+		flags |= inputUp
+	}
+	if input.Down {
+		flags |= inputDown
+	}
+	if input.Left {
+		flags |= inputLeft
+	}
+	if input.Right {
+		flags |= inputRight
+	}
+	data[4] = flags
+	return data, nil
+}
+
+func (input *SequencedInput) UnmarshalBinary(data []byte) error {
+	if len(data) != SequencedInputSize || data[4]&^(inputUp|inputDown|inputLeft|inputRight) != 0 {
+		return errors.New("invalid input")
+	}
+	input.Sequence = binary.LittleEndian.Uint32(data[0:])
+	input.Up = data[4]&inputUp != 0
+	input.Down = data[4]&inputDown != 0
+	input.Left = data[4]&inputLeft != 0
+	input.Right = data[4]&inputRight != 0
+	return nil
+}
+
+func (ack InputAck) MarshalBinary() ([]byte, error) {
+	data := make([]byte, 4, InputAckSize)
+	binary.LittleEndian.PutUint32(data, ack.Sequence)
+	transform, _ := ack.Transform.MarshalBinary()
+	return append(data, transform...), nil
+}
+
+func (ack *InputAck) UnmarshalBinary(data []byte) error {
+	if len(data) != InputAckSize {
+		return errors.New("invalid input ack size")
+	}
+	ack.Sequence = binary.LittleEndian.Uint32(data)
+	return ack.Transform.UnmarshalBinary(data[4:])
+}
@@ -0,0 +1,96 @@
+package physics
+
+import (
+	"gommo/engine/ecs"
+	"time"
+)
+
+const DefaultSnapshotHistory = 32
+
+type Snapshot struct {
+	Time time.Time
+	Transform
+}
+
+// Interpolation buffers timestamped server transforms of a remote entity, oldest first.
+type Interpolation struct {
+	snapshots    []Snapshot
+	maxSnapshots int
+}
+
+func NewInterpolation(maxSnapshots int) Interpolation {
+	return Interpolation{
+		snapshots:    make([]Snapshot, 0, maxSnapshots),
+		maxSnapshots: maxSnapshots,
+	}
+}
+
+func (interpolation *Interpolation) ComponentSet(val interface{}) {
+	*interpolation = val.(Interpolation)
+}
+
+// Add inserts a snapshot, keeping the buffer ordered even if packets arrive out of order.
+func (interpolation *Interpolation) Add(snapshot Snapshot) {
+	i := len(interpolation.snapshots)
+	for i > 0 && interpolation.snapshots[i-1].Time.After(snapshot.Time) {
+		i--
+	}
+	interpolation.snapshots = append(interpolation.snapshots, Snapshot{})
+	copy(interpolation.snapshots[i+1:], interpolation.snapshots[i:])
+	interpolation.snapshots[i] = snapshot
+
+	if len(interpolation.snapshots) > interpolation.maxSnapshots {
+		interpolation.snapshots = append(interpolation.snapshots[:0], interpolation.snapshots[1:]...)
+	}
+}
+
+// Sample returns the transform at renderTime, interpolating between the surrounding
+// snapshots. Past the newest snapshot it extrapolates for at most maxExtrapolation.
+func (interpolation *Interpolation) Sample(renderTime time.Time, maxExtrapolation time.Duration) (Transform, bool) {
+	snapshots := interpolation.snapshots
+	if len(snapshots) == 0 {
+		return Transform{}, false
+	}
+
+	if !renderTime.After(snapshots[0].Time) {
+		return snapshots[0].Transform, true
+	}
+
+	for i := 1; i < len(snapshots); i++ {
+		if !renderTime.After(snapshots[i].Time) {
+			return lerp(snapshots[i-1], snapshots[i], renderTime), true
+		}
+	}
+
+	last := snapshots[len(snapshots)-1]
+	if len(snapshots) == 1 {
+		return last.Transform, true
+	}
+	if renderTime.Sub(last.Time) > maxExtrapolation {
+		renderTime = last.Time.Add(maxExtrapolation)
+	}
+	return lerp(snapshots[len(snapshots)-2], last, renderTime), true
+}
+
+func lerp(a, b Snapshot, t time.Time) Transform {
+	span := b.Time.Sub(a.Time)
+	if span <= 0 {
+		return b.Transform
+	}
+	alpha := float64(t.Sub(a.Time)) / float64(span)
+	return Transform{
+		X: a.X + (b.X-a.X)*alpha,
+		Y: a.Y + (b.Y-a.Y)*alpha,
+	}
+}
+
+// AddSnapshot records a server transform for a remote entity, creating its buffer if needed.
+func AddSnapshot(engine *ecs.Engine, id ecs.Id, snapshot Snapshot) {
+	interpolation := Interpolation{}
+	ok := ecs.Read(engine, id, &interpolation)
+	if !ok {
+		interpolation = NewInterpolation(DefaultSnapshotHistory)
+	}
+	interpolation.Add(snapshot)
+	ecs.Write(engine, id, interpolation)
+}
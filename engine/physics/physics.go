@@ -19,7 +19,53 @@ func (input *Input) ComponentSet(val interface{}) {
 	*input = val.(Input)
 }
 
-func HandleInput(engine *ecs.Engine) {
+const MoveSpeed = 2.0
+
+// Move applies a single tick of input. It must stay a pure function of its arguments
+// so the client can replay inputs and land exactly where the server does.
+func Move(transform Transform, input Input) Transform {
+	if input.Left {
+		transform.X -= MoveSpeed
+	}
+	if input.Right {
+		transform.X += MoveSpeed
+	}
+	if input.Up {
+		transform.Y += MoveSpeed
+	}
+	if input.Down {
+		transform.Y -= MoveSpeed
+	}
+	return transform
+}
+
+// Movement is what stops a step of input. The client must predict with the same Movement
+// the server uses, so replayed inputs land where the server's do.
+type Movement struct {
+	Bounds Bounds
+	// Blocked reports whether an entity can't stand at a world position. Nil blocks nothing.
+	Blocked func(x float64, y float64) bool
+}
+
+// Step applies a single tick of input, clamped to the bounds. A step into a blocked
+// position is retried along each axis on its own, so entities slide along walls instead of
+// sticking to them. Entities that are already somewhere blocked, like a tile that was
+// edited under them, can always step out.
+func (movement Movement) Step(transform Transform, input Input) Transform {
+	next := movement.Bounds.Clamp(Move(transform, input))
+	if movement.Blocked == nil || !movement.Blocked(next.X, next.Y) || movement.Blocked(transform.X, transform.Y) {
+		return next
+	}
+	if !movement.Blocked(next.X, transform.Y) {
+		return Transform{X: next.X, Y: transform.Y}
+	}
+	if !movement.Blocked(transform.X, next.Y) {
+		return Transform{X: transform.X, Y: next.Y}
+	}
+	return transform
+}
+
+func HandleInput(engine *ecs.Engine, movement Movement) {
 	ecs.Each(engine, Input{}, func(id ecs.Id, a interface{}) {
 		input := a.(Input)
 
@@ -29,18 +75,7 @@ func HandleInput(engine *ecs.Engine) {
 			return
 		}
 
-		if input.Left {
-			transform.X -= 2.0
-		}
-		if input.Right {
-			transform.X += 2.0
-		}
-		if input.Up {
-			transform.Y += 2.0
-		}
-		if input.Down {
-			transform.Y -= 2.0
-		}
+		transform = movement.Step(transform, input)
 
 		ecs.Write(engine, id, transform)
 	})
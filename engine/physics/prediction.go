@@ -0,0 +1,111 @@
+package physics
+
+import "gommo/engine/ecs"
+
+const (
+	DefaultPredictionHistory = 128
+	correctionDecay          = 0.8
+	correctionSnap           = 0.01
+)
+
+type SequencedInput struct {
+	Sequence uint32
+	Input
+}
+
+// InputAck is the server's reply to a SequencedInput, holding the last sequence number it
+// applied and the transform that left the entity at.
+type InputAck struct {
+	Sequence uint32
+	Transform
+}
+
+// Prediction tracks the inputs the local player has applied but the server has not
+// acknowledged yet, so they can be replayed on top of each authoritative state.
+type Prediction struct {
+	nextSequence uint32
+	history      []SequencedInput
+	maxHistory   int
+
+	// Visual error left over from the last correction, drawn on top of the transform
+	// and decayed every tick so mispredictions ease out instead of snapping.
+	OffsetX, OffsetY float64
+}
+
+func NewPrediction(maxHistory int) Prediction {
+	return Prediction{
+		nextSequence: 1,
+		history:      make([]SequencedInput, 0, maxHistory),
+		maxHistory:   maxHistory,
+	}
+}
+
+func (prediction *Prediction) ComponentSet(val interface{}) { *prediction = val.(Prediction) }
+
+// Record tags the input applied this tick with the next sequence number.
+func (prediction *Prediction) Record(input Input) SequencedInput {
+	sequenced := SequencedInput{Sequence: prediction.nextSequence, Input: input}
+	prediction.nextSequence++
+
+	if len(prediction.history) >= prediction.maxHistory {
+		prediction.history = append(prediction.history[:0], prediction.history[1:]...)
+	}
+	prediction.history = append(prediction.history, sequenced)
+
+	prediction.decayOffset()
+	return sequenced
+}
+
+// Reconcile drops every input up to ack and replays the rest on top of the server state,
+// stepping with the same movement rules as the physics systems. The difference to the
+// currently predicted transform is kept as a visual offset.
+func (prediction *Prediction) Reconcile(predicted Transform, server Transform, ack uint32, movement Movement) Transform {
+	pending := prediction.history[:0]
+	for _, input := range prediction.history {
+		if input.Sequence > ack {
+			pending = append(pending, input)
+		}
+	}
+	prediction.history = pending
+
+	corrected := server
+	for _, input := range prediction.history {
+		corrected = movement.Step(corrected, input.Input)
+	}
+
+	prediction.OffsetX += predicted.X - corrected.X
+	prediction.OffsetY += predicted.Y - corrected.Y
+	return corrected
+}
+
+func (prediction *Prediction) Pending() int {
+	return len(prediction.history)
+}
+
+func (prediction *Prediction) decayOffset() {
+	prediction.OffsetX *= correctionDecay
+	prediction.OffsetY *= correctionDecay
+	if prediction.OffsetX*prediction.OffsetX+prediction.OffsetY*prediction.OffsetY < correctionSnap {
+		prediction.OffsetX = 0
+		prediction.OffsetY = 0
+	}
+}
+
+// RecordInputs tags the current input of every predicted entity, returning the tagged
+// inputs so they can be sent to the server.
+func RecordInputs(engine *ecs.Engine) map[ecs.Id]SequencedInput {
+	recorded := make(map[ecs.Id]SequencedInput)
+	ecs.Each(engine, Prediction{}, func(id ecs.Id, a interface{}) {
+		prediction := a.(Prediction)
+
+		input := Input{}
+		ok := ecs.Read(engine, id, &input)
+		if !ok {
+			return
+		}
+
+		recorded[id] = prediction.Record(input)
+		ecs.Write(engine, id, prediction)
+	})
+	return recorded
+}
@@ -0,0 +1,175 @@
+package proceduralgeneration
+
+import (
+	"fmt"
+)
+
+// NoiseSpec describes a graph of noise in JSON, for example
+//
+//	{"type": "remap", "from": [-1, 1], "to": [0, 1], "source":
+//		{"type": "ridged", "octaves": 5, "frequency": 0.01}}
+//
+// Seed is added to the seed the graph is built with, so sibling nodes can be decorrelated.
+// Fields a node type doesn't use are ignored.
+type NoiseSpec struct {
+	Type string `json:"type"`
+	Seed int64  `json:"seed"`
+
+	// simplex, worley, fbm, billow and ridged
+	Frequency float64 `json:"frequency"`
+
+	// fbm, billow and ridged
+	Octaves     int     `json:"octaves"`
+	Lacunarity  float64 `json:"lacunarity"`
+	Persistence float64 `json:"persistence"`
+
+	// warp samples Warp twice, with consecutive seeds, to offset x and y
+	Warp     *NoiseSpec `json:"warp"`
+	Strength float64    `json:"strength"`
+
+	// clamp
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+
+	// remap
+	From []float64 `json:"from"`
+	To   []float64 `json:"to"`
+
+	// constant
+	Value float64 `json:"value"`
+
+	// fbm, billow, ridged, warp, clamp and remap use Source. fbm, billow and ridged
+	// default to simplex noise.
+	Source *NoiseSpec `json:"source"`
+	// add and multiply
+	Sources []NoiseSpec `json:"sources"`
+}
+
+const (
+	defaultOctaves     = 4
+	defaultLacunarity  = 2.0
+	defaultPersistence = 0.5
+)
+
+// Build creates the Noise described by the graph.
+func (spec *NoiseSpec) Build(seed int64) (Noise, error) {
+	seed += spec.Seed
+	switch spec.Type {
+	case "simplex":
+		return Scale{Source: NewSimplex(seed), Frequency: spec.frequency()}, nil
+	case "worley":
+		return Scale{Source: Worley{Seed: seed}, Frequency: spec.frequency()}, nil
+	case "fbm", "billow", "ridged":
+		fractal, err := spec.fractal(seed)
+		if err != nil {
+			return nil, err
+		}
+		switch spec.Type {
+		case "fbm":
+			return FBM(fractal), nil
+		case "billow":
+			return Billow(fractal), nil
+		}
+		return Ridged(fractal), nil
+	case "warp":
+		if spec.Warp == nil {
+			return nil, fmt.Errorf("noise: warp needs a warp noise")
+		}
+		source, err := spec.source(seed)
+		if err != nil {
+			return nil, err
+		}
+		warpX, err := spec.Warp.Build(seed)
+		if err != nil {
+			return nil, err
+		}
+		warpY, err := spec.Warp.Build(seed + 1)
+		if err != nil {
+			return nil, err
+		}
+		return Warp{Source: source, WarpX: warpX, WarpY: warpY, Strength: spec.Strength}, nil
+	case "add", "multiply":
+		sources, err := spec.sources(seed)
+		if err != nil {
+			return nil, err
+		}
+		if spec.Type == "add" {
+			return Add(sources), nil
+		}
+		return Multiply(sources), nil
+	case "clamp":
+		source, err := spec.source(seed)
+		if err != nil {
+			return nil, err
+		}
+		return Clamp{Source: source, Min: spec.Min, Max: spec.Max}, nil
+	case "remap":
+		if len(spec.From) != 2 || len(spec.To) != 2 {
+			return nil, fmt.Errorf("noise: remap from and to must be [min, max]")
+		}
+		source, err := spec.source(seed)
+		if err != nil {
+			return nil, err
+		}
+		return Remap{Source: source, FromMin: spec.From[0], FromMax: spec.From[1], ToMin: spec.To[0], ToMax: spec.To[1]}, nil
+	case "constant":
+		return Constant(spec.Value), nil
+	}
+	return nil, fmt.Errorf("noise: unknown type %q", spec.Type)
+}
+
+func (spec *NoiseSpec) frequency() float64 {
+	if spec.Frequency == 0 {
+		return 1
+	}
+	return spec.Frequency
+}
+
+func (spec *NoiseSpec) fractal(seed int64) (Fractal, error) {
+	fractal := Fractal{
+		Source:      NewSimplex(seed),
+		Octaves:     spec.Octaves,
+		Frequency:   spec.frequency(),
+		Lacunarity:  spec.Lacunarity,
+		Persistence: spec.Persistence,
+	}
+	if spec.Source != nil {
+		source, err := spec.Source.Build(seed)
+		if err != nil {
+			return fractal, err
+		}
+		fractal.Source = source
+	}
+	if fractal.Octaves <= 0 {
+		fractal.Octaves = defaultOctaves
+	}
+	if fractal.Lacunarity == 0 {
+		fractal.Lacunarity = defaultLacunarity
+	}
+	if fractal.Persistence == 0 {
+		fractal.Persistence = defaultPersistence
+	}
+	return fractal, nil
+}
+
+func (spec *NoiseSpec) source(seed int64) (Noise, error) {
+	if spec.Source == nil {
+		return nil, fmt.Errorf("noise: %s needs a source", spec.Type)
+	}
+	return spec.Source.Build(seed)
+}
+
+func (spec *NoiseSpec) sources(seed int64) ([]Noise, error) {
+	if len(spec.Sources) == 0 {
+		return nil, fmt.Errorf("noise: %s needs at least one source", spec.Type)
+	}
+	sources := make([]Noise, len(spec.Sources))
+	for i := range spec.Sources {
+		var err error
+		sources[i], err = spec.Sources[i].Build(seed)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return sources, nil
+}
@@ -0,0 +1,28 @@
+package proceduralgeneration
+
+// Heightmap stores one value per tile, for example the height, moisture or temperature.
+type Heightmap struct {
+	Width  int
+	Height int
+	values []float64
+}
+
+func NewHeightmap(width int, height int) *Heightmap {
+	return &Heightmap{
+		Width:  width,
+		Height: height,
+		values: make([]float64, width*height),
+	}
+}
+
+func (heightmap *Heightmap) Get(x int, y int) float64 {
+	return heightmap.values[x*heightmap.Height+y]
+}
+
+func (heightmap *Heightmap) Set(x int, y int, value float64) {
+	heightmap.values[x*heightmap.Height+y] = value
+}
+
+func (heightmap *Heightmap) InBounds(x int, y int) bool {
+	return x >= 0 && x < heightmap.Width && y >= 0 && y < heightmap.Height
+}
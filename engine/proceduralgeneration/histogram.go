@@ -0,0 +1,40 @@
+package proceduralgeneration
+
+// Histogram counts values in [0, 1] so thresholds can be picked by the share of values
+// below them.
+type Histogram struct {
+	bins  []int
+	total int
+}
+
+func NewHistogram(bins int) *Histogram {
+	return &Histogram{bins: make([]int, bins)}
+}
+
+// Add counts v, after clamping it to [0, 1].
+func (histogram *Histogram) Add(v float64) {
+	bin := int(Unit(v) * float64(len(histogram.bins)))
+	if bin == len(histogram.bins) {
+		bin--
+	}
+	histogram.bins[bin]++
+	histogram.total++
+}
+
+// Percentile returns the value that fraction p of the counted values fall below,
+// interpolating within the bin it lands in.
+func (histogram *Histogram) Percentile(p float64) float64 {
+	if histogram.total == 0 {
+		return 0
+	}
+	target := Unit(p) * float64(histogram.total)
+	width := 1 / float64(len(histogram.bins))
+	below := 0.0
+	for i, count := range histogram.bins {
+		if below+float64(count) >= target && count > 0 {
+			return (float64(i) + (target-below)/float64(count)) * width
+		}
+		below += float64(count)
+	}
+	return 1
+}
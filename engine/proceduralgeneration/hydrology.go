@@ -0,0 +1,141 @@
+package proceduralgeneration
+
+import (
+	"container/heap"
+)
+
+// drainageSlope is added to every filled tile over the tile it drains into, so that
+// filled depressions still slope gently towards their outlet.
+const drainageSlope = 1e-6
+
+// Drainage routes water over a heightmap. Depressions are filled up to the height of
+// their lowest outlet, so following Downstream from any tile always ends at the sea or
+// the edge of the map.
+type Drainage struct {
+	Heights    *Heightmap
+	Filled     *Heightmap
+	downstream []int
+}
+
+// NewDrainage fills the depressions of heights using a priority flood, starting from
+// every tile at or below seaLevel and every tile on the edge of the map.
+func NewDrainage(heights *Heightmap, seaLevel float64) *Drainage {
+	drainage := &Drainage{
+		Heights:    heights,
+		Filled:     NewHeightmap(heights.Width, heights.Height),
+		downstream: make([]int, len(heights.values)),
+	}
+
+	outlet := func(x int, y int) bool {
+		return heights.Get(x, y) <= seaLevel || x == 0 || y == 0 || x == heights.Width-1 || y == heights.Height-1
+	}
+
+	visited := make([]bool, len(heights.values))
+	open := &floodQueue{}
+	for i, height := range heights.values {
+		x, y := i/heights.Height, i%heights.Height
+		if !outlet(x, y) {
+			continue
+		}
+		visited[i] = true
+		drainage.downstream[i] = -1
+		drainage.Filled.values[i] = height
+
+		// Only outlets next to land can drain anything, leaving the open sea out of the queue
+		for _, d := range neighbors {
+			nx, ny := x+d[0], y+d[1]
+			if heights.InBounds(nx, ny) && !outlet(nx, ny) {
+				*open = append(*open, floodItem{index: i, height: height})
+				break
+			}
+		}
+	}
+	heap.Init(open)
+
+	for open.Len() > 0 {
+		item := heap.Pop(open).(floodItem)
+		x, y := item.index/heights.Height, item.index%heights.Height
+		for _, d := range neighbors {
+			nx, ny := x+d[0], y+d[1]
+			if !heights.InBounds(nx, ny) {
+				continue
+			}
+			n := nx*heights.Height + ny
+			if visited[n] {
+				continue
+			}
+			visited[n] = true
+
+			filled := heights.values[n]
+			if filled < item.height+drainageSlope {
+				filled = item.height + drainageSlope
+			}
+			drainage.Filled.values[n] = filled
+			drainage.downstream[n] = item.index
+			heap.Push(open, floodItem{index: n, height: filled})
+		}
+	}
+	return drainage
+}
+
+// Downstream returns the tile that water on (x, y) flows into. It returns false for the
+// sea and the edge of the map, where water leaves the heightmap.
+func (drainage *Drainage) Downstream(x int, y int) (int, int, bool) {
+	next := drainage.downstream[x*drainage.Heights.Height+y]
+	if next < 0 {
+		return x, y, false
+	}
+	return next / drainage.Heights.Height, next % drainage.Heights.Height, true
+}
+
+// Depth returns how far (x, y) had to be raised to let water drain out of it. Tiles with
+// a noticeable depth are the bottom of a lake.
+func (drainage *Drainage) Depth(x int, y int) float64 {
+	return drainage.Filled.Get(x, y) - drainage.Heights.Get(x, y)
+}
+
+// Trace calls visit for every tile from (x, y) down to the sea, stopping early if visit
+// returns false.
+func (drainage *Drainage) Trace(x int, y int, visit func(x int, y int) bool) {
+	for {
+		if !visit(x, y) {
+			return
+		}
+		var ok bool
+		x, y, ok = drainage.Downstream(x, y)
+		if !ok {
+			return
+		}
+	}
+}
+
+var neighbors = [8][2]int{{1, 0}, {0, 1}, {-1, 0}, {0, -1}, {1, 1}, {-1, 1}, {-1, -1}, {1, -1}}
+
+type floodItem struct {
+	index  int
+	height float64
+}
+
+// floodQueue pops the lowest tile first, breaking ties by index so the result only
+// depends on the heightmap.
+type floodQueue []floodItem
+
+func (q floodQueue) Len() int { return len(q) }
+func (q floodQueue) Less(i, j int) bool {
+	if q[i].height != q[j].height {
+		return q[i].height < q[j].height
+	}
+	return q[i].index < q[j].index
+}
+func (q floodQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *floodQueue) Push(x interface{}) {
+	*q = append(*q, x.(floodItem))
+}
+
+func (q *floodQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
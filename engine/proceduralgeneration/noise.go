@@ -0,0 +1,190 @@
+package proceduralgeneration
+
+import (
+	"github.com/ojrac/opensimplex-go"
+	"math"
+)
+
+// Noise is a deterministic 2D noise function. Unless stated otherwise noise returns values
+// in [-1, 1]. Implementations don't hold any mutable state, so they are safe to share
+// between goroutines.
+type Noise interface {
+	Eval(x float64, y float64) float64
+}
+
+// NoiseFunc lets an ordinary function be used as Noise.
+type NoiseFunc func(x float64, y float64) float64
+
+func (f NoiseFunc) Eval(x float64, y float64) float64 {
+	return f(x, y)
+}
+
+type simplex struct {
+	noise opensimplex.Noise
+}
+
+// NewSimplex returns OpenSimplex noise with a feature size of roughly one unit.
+func NewSimplex(seed int64) Noise {
+	return simplex{noise: opensimplex.New(seed)}
+}
+
+func (s simplex) Eval(x float64, y float64) float64 {
+	return s.noise.Eval2(x, y)
+}
+
+// Fractal sums octaves of a source noise. Each octave is sampled at Lacunarity times the
+// frequency of the previous one and weighted by Persistence times its amplitude.
+type Fractal struct {
+	Source      Noise
+	Octaves     int
+	Frequency   float64
+	Lacunarity  float64
+	Persistence float64
+}
+
+// octave offsets stop every octave lining up at the origin.
+const octaveOffset = 31.7
+
+func (fractal Fractal) each(x float64, y float64, f func(n float64, amplitude float64)) {
+	frequency, amplitude := fractal.Frequency, 1.0
+	for i := 0; i < fractal.Octaves; i++ {
+		offset := float64(i) * octaveOffset
+		f(fractal.Source.Eval(x*frequency+offset, y*frequency+offset), amplitude)
+		frequency *= fractal.Lacunarity
+		amplitude *= fractal.Persistence
+	}
+}
+
+// FBM is fractal Brownian motion, the usual rolling hills.
+type FBM Fractal
+
+func (fbm FBM) Eval(x float64, y float64) float64 {
+	sum, total := 0.0, 0.0
+	Fractal(fbm).each(x, y, func(n float64, amplitude float64) {
+		sum += n * amplitude
+		total += amplitude
+	})
+	return safeDivide(sum, total)
+}
+
+// Billow folds every octave, giving rounded lumps like clouds or dunes.
+type Billow Fractal
+
+func (billow Billow) Eval(x float64, y float64) float64 {
+	sum, total := 0.0, 0.0
+	Fractal(billow).each(x, y, func(n float64, amplitude float64) {
+		sum += (2*math.Abs(n) - 1) * amplitude
+		total += amplitude
+	})
+	return safeDivide(sum, total)
+}
+
+// Ridged is a ridged multifractal. Each octave is weighted by the one before it, so
+// detail builds up along sharp ridges like mountain ranges.
+type Ridged Fractal
+
+func (ridged Ridged) Eval(x float64, y float64) float64 {
+	sum, total, weight := 0.0, 0.0, 1.0
+	Fractal(ridged).each(x, y, func(n float64, amplitude float64) {
+		signal := 1 - math.Abs(n)
+		signal *= signal * weight
+		weight = clamp(signal*2, 0, 1)
+		sum += signal * amplitude
+		total += amplitude
+	})
+	return safeDivide(sum, total)*2 - 1
+}
+
+// Warp distorts the coordinates given to Source by the values of WarpX and WarpY.
+type Warp struct {
+	Source   Noise
+	WarpX    Noise
+	WarpY    Noise
+	Strength float64
+}
+
+func (warp Warp) Eval(x float64, y float64) float64 {
+	dx := warp.WarpX.Eval(x, y) * warp.Strength
+	dy := warp.WarpY.Eval(x, y) * warp.Strength
+	return warp.Source.Eval(x+dx, y+dy)
+}
+
+// Scale samples Source at Frequency times the coordinates.
+type Scale struct {
+	Source    Noise
+	Frequency float64
+}
+
+func (scale Scale) Eval(x float64, y float64) float64 {
+	return scale.Source.Eval(x*scale.Frequency, y*scale.Frequency)
+}
+
+type Constant float64
+
+func (constant Constant) Eval(x float64, y float64) float64 {
+	return float64(constant)
+}
+
+// Add sums its sources.
+type Add []Noise
+
+func (add Add) Eval(x float64, y float64) float64 {
+	sum := 0.0
+	for _, noise := range add {
+		sum += noise.Eval(x, y)
+	}
+	return sum
+}
+
+// Multiply multiplies its sources together.
+type Multiply []Noise
+
+func (multiply Multiply) Eval(x float64, y float64) float64 {
+	product := 1.0
+	for _, noise := range multiply {
+		product *= noise.Eval(x, y)
+	}
+	return product
+}
+
+type Clamp struct {
+	Source Noise
+	Min    float64
+	Max    float64
+}
+
+func (c Clamp) Eval(x float64, y float64) float64 {
+	return clamp(c.Source.Eval(x, y), c.Min, c.Max)
+}
+
+// Remap linearly maps [FromMin, FromMax] onto [ToMin, ToMax]. Values outside the source
+// range are extrapolated, so wrap it in a Clamp if they must stay within the target range.
+type Remap struct {
+	Source  Noise
+	FromMin float64
+	FromMax float64
+	ToMin   float64
+	ToMax   float64
+}
+
+func (remap Remap) Eval(x float64, y float64) float64 {
+	t := safeDivide(remap.Source.Eval(x, y)-remap.FromMin, remap.FromMax-remap.FromMin)
+	return remap.ToMin + t*(remap.ToMax-remap.ToMin)
+}
+
+func clamp(v float64, min float64, max float64) float64 {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+func safeDivide(a float64, b float64) float64 {
+	if b == 0 {
+		return 0
+	}
+	return a / b
+}
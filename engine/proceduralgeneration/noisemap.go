@@ -6,34 +6,59 @@ import (
 )
 
 type Octave struct {
-	Frequency float64
-	Scale     float64
+	Frequency float64 `json:"frequency"`
+	Scale     float64 `json:"scale"`
 }
 
+// NoiseMap sums octaves of noise and raises the result to exponent. The sum is normalized
+// by the range the octave scales allow, so the result is always in [0, 1] however the
+// octaves are tuned.
 type NoiseMap struct {
 	seed     int64
 	noise    opensimplex.Noise
 	octaves  []Octave
 	exponent float64
+	min, max float64
 }
 
 func NewNoiseMap(seed int64, octaves []Octave, exponent float64) *NoiseMap {
-	return &NoiseMap{
+	n := &NoiseMap{
 		seed:     seed,
 		noise:    opensimplex.NewNormalized(seed),
 		octaves:  octaves,
 		exponent: exponent,
 	}
+	for _, octave := range octaves {
+		if octave.Scale < 0 {
+			n.min += octave.Scale
+		} else {
+			n.max += octave.Scale
+		}
+	}
+	return n
 }
 
 func (n *NoiseMap) Get(x int, y int) float64 {
+	return n.Eval(float64(x), float64(y))
+}
+
+// Eval lets a NoiseMap be used as Noise. Unlike other Noise it returns values in [0, 1].
+func (n *NoiseMap) Eval(x float64, y float64) float64 {
 	ret := 0.0
 	for i := range n.octaves {
-		xNoise := n.octaves[i].Frequency * float64(x)
-		yNoise := n.octaves[i].Frequency * float64(y)
+		xNoise := n.octaves[i].Frequency * x
+		yNoise := n.octaves[i].Frequency * y
 		ret += n.octaves[i].Scale * n.noise.Eval2(xNoise, yNoise)
 	}
 
-	ret = math.Pow(ret, n.exponent)
-	return ret
+	ret = Unit(safeDivide(ret-n.min, n.max-n.min))
+	return Unit(math.Pow(ret, n.exponent))
+}
+
+// Unit clamps v to [0, 1], mapping NaN to 0.
+func Unit(v float64) float64 {
+	if math.IsNaN(v) {
+		return 0
+	}
+	return clamp(v, 0, 1)
 }
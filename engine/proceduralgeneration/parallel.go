@@ -0,0 +1,37 @@
+package proceduralgeneration
+
+import (
+	"runtime"
+	"sync"
+)
+
+// DefaultWorkers uses every CPU.
+var DefaultWorkers = runtime.GOMAXPROCS(0)
+
+// Parallel calls f for every i in [0, count), spread over workers goroutines. As long as f
+// only writes state that belongs to i, the result is the same as a plain loop however the
+// work is scheduled.
+func Parallel(count int, workers int, f func(i int)) {
+	if workers > count {
+		workers = count
+	}
+	if workers <= 1 {
+		for i := 0; i < count; i++ {
+			f(i)
+		}
+		return
+	}
+
+	wg := sync.WaitGroup{}
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func(w int) {
+			defer wg.Done()
+			// Interleave so neighbouring, similarly expensive indices go to different workers
+			for i := w; i < count; i += workers {
+				f(i)
+			}
+		}(w)
+	}
+	wg.Wait()
+}
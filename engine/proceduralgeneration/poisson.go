@@ -0,0 +1,200 @@
+package proceduralgeneration
+
+import (
+	"gommo/engine/random"
+	"math"
+	"math/rand"
+	"sync"
+)
+
+const DefaultPoissonAttempts = 30
+
+type Point struct {
+	X, Y float64
+}
+
+// PoissonDisk scatters points over [0, width) x [0, height) so that no two are closer than
+// radius, using Bridson's algorithm. Each active point tries attempts candidates before it
+// is retired.
+func PoissonDisk(rng *rand.Rand, width float64, height float64, radius float64, attempts int) []Point {
+	if width <= 0 || height <= 0 || radius <= 0 {
+		return nil
+	}
+
+	cellSize := radius / math.Sqrt2
+	cols, rows := int(math.Ceil(width/cellSize)), int(math.Ceil(height/cellSize))
+	grid := make([]int, cols*rows)
+	for i := range grid {
+		grid[i] = -1
+	}
+	points := []Point{}
+	cell := func(p Point) (int, int) {
+		return int(p.X / cellSize), int(p.Y / cellSize)
+	}
+	fits := func(p Point) bool {
+		cx, cy := cell(p)
+		for x := cx - 2; x <= cx+2; x++ {
+			for y := cy - 2; y <= cy+2; y++ {
+				if x < 0 || y < 0 || x >= cols || y >= rows || grid[x*rows+y] < 0 {
+					continue
+				}
+				if distanceSquared(p, points[grid[x*rows+y]]) < radius*radius {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	add := func(p Point) {
+		cx, cy := cell(p)
+		grid[cx*rows+cy] = len(points)
+		points = append(points, p)
+	}
+
+	add(Point{X: rng.Float64() * width, Y: rng.Float64() * height})
+	active := []int{0}
+	for len(active) > 0 {
+		i := rng.Intn(len(active))
+		origin := points[active[i]]
+		found := false
+		for attempt := 0; attempt < attempts; attempt++ {
+			p := annulusPoint(rng, origin, radius)
+			if p.X < 0 || p.Y < 0 || p.X >= width || p.Y >= height || !fits(p) {
+				continue
+			}
+			active = append(active, len(points))
+			add(p)
+			found = true
+			break
+		}
+		if !found {
+			active[i] = active[len(active)-1]
+			active = active[:len(active)-1]
+		}
+	}
+	return points
+}
+
+// annulusPoint picks a point between radius and twice radius from origin. Rejection
+// sampling avoids the cost of trigonometry.
+func annulusPoint(rng *rand.Rand, origin Point, radius float64) Point {
+	for {
+		dx := (rng.Float64()*4 - 2) * radius
+		dy := (rng.Float64()*4 - 2) * radius
+		d := dx*dx + dy*dy
+		if d >= radius*radius && d < 4*radius*radius {
+			return Point{X: origin.X + dx, Y: origin.Y + dy}
+		}
+	}
+}
+
+func distanceSquared(a Point, b Point) float64 {
+	return (a.X-b.X)*(a.X-b.X) + (a.Y-b.Y)*(a.Y-b.Y)
+}
+
+// ChunkedPoisson scatters points over an unbounded plane one square chunk at a time. Each
+// chunk is sampled on its own from its chunk stream, then points too close to a point with
+// a higher priority in a neighbouring chunk are dropped. A chunk only depends on the streams
+// and purpose, so it can be regenerated anywhere in any order.
+type ChunkedPoisson struct {
+	streams   random.Streams
+	purpose   string
+	chunkSize float64
+	radius    float64
+
+	mu    sync.Mutex
+	cache map[[2]int][]poissonCandidate
+}
+
+const maxPoissonCacheSize = 4096
+
+type poissonCandidate struct {
+	Point
+	priority uint64
+}
+
+// NewChunkedPoisson creates a sampler for points at least radius apart, drawing each chunk
+// from the streams' chunk stream for purpose. Radius must be smaller than chunkSize.
+func NewChunkedPoisson(streams random.Streams, purpose string, chunkSize float64, radius float64) *ChunkedPoisson {
+	return &ChunkedPoisson{
+		streams:   streams,
+		purpose:   purpose,
+		chunkSize: chunkSize,
+		radius:    radius,
+		cache:     make(map[[2]int][]poissonCandidate),
+	}
+}
+
+// Chunk returns the points in [chunkX, chunkX+1) x [chunkY, chunkY+1) scaled by the chunk
+// size. Points for which keep returns false are removed before points in neighbouring
+// chunks are compared, so they don't leave gaps. keep must only depend on the point.
+func (chunked *ChunkedPoisson) Chunk(chunkX int, chunkY int, keep func(p Point) bool) []Point {
+	if keep == nil {
+		keep = func(p Point) bool { return true }
+	}
+
+	points := []Point{}
+	for _, p := range chunked.candidates(chunkX, chunkY) {
+		if keep(p.Point) && !chunked.outranked(p, chunkX, chunkY, keep) {
+			points = append(points, p.Point)
+		}
+	}
+	return points
+}
+
+// outranked reports whether a kept point in a neighbouring chunk with a higher priority
+// is too close to p.
+func (chunked *ChunkedPoisson) outranked(p poissonCandidate, chunkX int, chunkY int, keep func(p Point) bool) bool {
+	minX, minY := float64(chunkX)*chunked.chunkSize, float64(chunkY)*chunked.chunkSize
+	maxX, maxY := minX+chunked.chunkSize, minY+chunked.chunkSize
+	for dx := -1; dx <= 1; dx++ {
+		for dy := -1; dy <= 1; dy++ {
+			if (dx == 0 && dy == 0) ||
+				(dx < 0 && p.X-minX >= chunked.radius) || (dx > 0 && maxX-p.X >= chunked.radius) ||
+				(dy < 0 && p.Y-minY >= chunked.radius) || (dy > 0 && maxY-p.Y >= chunked.radius) {
+				continue
+			}
+			for _, other := range chunked.candidates(chunkX+dx, chunkY+dy) {
+				if other.priority > p.priority && distanceSquared(p.Point, other.Point) < chunked.radius*chunked.radius && keep(other.Point) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (chunked *ChunkedPoisson) ClearCache() {
+	chunked.mu.Lock()
+	chunked.cache = make(map[[2]int][]poissonCandidate)
+	chunked.mu.Unlock()
+}
+
+func (chunked *ChunkedPoisson) candidates(chunkX int, chunkY int) []poissonCandidate {
+	key := [2]int{chunkX, chunkY}
+	chunked.mu.Lock()
+	candidates, ok := chunked.cache[key]
+	chunked.mu.Unlock()
+	if ok {
+		return candidates
+	}
+
+	rng := chunked.streams.Chunk(chunked.purpose, chunkX, chunkY)
+	points := PoissonDisk(rng, chunked.chunkSize, chunked.chunkSize, chunked.radius, DefaultPoissonAttempts)
+
+	candidates = make([]poissonCandidate, len(points))
+	for i, p := range points {
+		candidates[i] = poissonCandidate{
+			Point:    Point{X: p.X + float64(chunkX)*chunked.chunkSize, Y: p.Y + float64(chunkY)*chunked.chunkSize},
+			priority: rng.Uint64(),
+		}
+	}
+
+	chunked.mu.Lock()
+	if len(chunked.cache) >= maxPoissonCacheSize {
+		chunked.cache = make(map[[2]int][]poissonCandidate)
+	}
+	chunked.cache[key] = candidates
+	chunked.mu.Unlock()
+	return candidates
+}
@@ -0,0 +1,31 @@
+package proceduralgeneration
+
+import (
+	"gommo/engine/random"
+	"math"
+)
+
+// Worley is cellular noise. Space is split into unit cells that each hold one randomly
+// placed feature point, and the noise is the distance to the nearest point, mapped from
+// [0, 1] to [-1, 1]. It makes cracked, cell-like patterns such as plates or stones.
+type Worley struct {
+	Seed int64
+}
+
+func (worley Worley) Eval(x float64, y float64) float64 {
+	cellX, cellY := math.Floor(x), math.Floor(y)
+	nearest := math.Inf(1)
+	for dx := -1.0; dx <= 1; dx++ {
+		for dy := -1.0; dy <= 1; dy++ {
+			cx, cy := cellX+dx, cellY+dy
+			h := random.Hash(uint64(worley.Seed), uint64(int64(cx)), uint64(int64(cy)))
+			px := cx + float64(h>>40)/(1<<24)
+			py := cy + float64(h&0xFFFFFF)/(1<<24)
+			d := (px-x)*(px-x) + (py-y)*(py-y)
+			if d < nearest {
+				nearest = d
+			}
+		}
+	}
+	return clamp(math.Sqrt(nearest), 0, 1)*2 - 1
+}
@@ -0,0 +1,86 @@
+package random
+
+import (
+	"hash/fnv"
+	"math/rand"
+)
+
+// Streams derives independent random number generators from a world seed. A stream only
+// depends on the seed and the key it is asked for, never on which streams were used before
+// it or on which goroutine asks, so chunks can be generated in any order and still come out
+// the same. Streams holds no state and is safe to share.
+type Streams struct {
+	WorldSeed int64
+}
+
+func New(worldSeed int64) Streams {
+	return Streams{WorldSeed: worldSeed}
+}
+
+// Streams is stored on the engine as a resource.
+func (streams *Streams) ComponentSet(val interface{}) { *streams = val.(Streams) }
+
+// Key names a stream. Purpose separates unrelated decisions, like "loot" and "objects/oak",
+// so adding one never shifts the numbers another sees. Decisions tied to a single tile, like
+// naming the region starting there, use the tile instead of the chunk. Anything a decision
+// isn't tied to is left at zero.
+type Key struct {
+	Purpose        string
+	ChunkX, ChunkY int
+	TileX, TileY   int
+	Tick           uint64
+}
+
+// Seed hashes the key into a seed for the stream.
+func (streams Streams) Seed(key Key) int64 {
+	purpose := fnv.New64a()
+	purpose.Write([]byte(key.Purpose))
+
+	return int64(Hash(uint64(streams.WorldSeed)^purpose.Sum64(), uint64(int64(key.ChunkX)), uint64(int64(key.ChunkY)),
+		uint64(int64(key.TileX)), uint64(int64(key.TileY)), key.Tick))
+}
+
+// Stream returns a new generator for the key. Each call starts the stream from the
+// beginning, and the generator must not be shared between goroutines.
+func (streams Streams) Stream(key Key) *rand.Rand {
+	return rand.New(rand.NewSource(streams.Seed(key)))
+}
+
+// Chunk is shorthand for the stream of a chunk that doesn't change over time.
+func (streams Streams) Chunk(purpose string, chunkX int, chunkY int) *rand.Rand {
+	return streams.Stream(Key{Purpose: purpose, ChunkX: chunkX, ChunkY: chunkY})
+}
+
+// Scatter returns the per position values for a purpose. Decisions made for every tile,
+// like where decorations go, use it instead of a generator per tile.
+func (streams Streams) Scatter(purpose string) Scatter {
+	return Scatter{seed: uint64(streams.Seed(Key{Purpose: purpose}))}
+}
+
+// Scatter hashes positions into stable values. It holds no state and is safe to share.
+type Scatter struct {
+	seed uint64
+}
+
+// Roll returns the value in [0, 1) for a position.
+func (scatter Scatter) Roll(x int, y int) float64 {
+	return float64(Hash(scatter.seed, uint64(int64(x)), uint64(int64(y)))>>11) / (1 << 53)
+}
+
+// Hash combines values into one well mixed 64 bit hash. Everything random in the game is
+// derived from it, so nearby inputs never give related outputs.
+func Hash(values ...uint64) uint64 {
+	h := uint64(0)
+	for _, v := range values {
+		h = mix(h ^ v)
+	}
+	return h
+}
+
+// mix is the splitmix64 finalizer, so that nearby keys give unrelated seeds.
+func mix(h uint64) uint64 {
+	h += 0x9E3779B97F4A7C15
+	h = (h ^ (h >> 30)) * 0xBF58476D1CE4E5B9
+	h = (h ^ (h >> 27)) * 0x94D049BB133111EB
+	return h ^ (h >> 31)
+}
@@ -0,0 +1,131 @@
+package raycast
+
+import "gommo/engine/tilemap"
+
+type FieldOfView struct {
+	OriginX, OriginY int
+	Radius           int
+	visible          []bool
+}
+
+func (fov *FieldOfView) index(x, y int) (int, bool) {
+	dx := x - fov.OriginX + fov.Radius
+	dy := y - fov.OriginY + fov.Radius
+	size := 2*fov.Radius + 1
+	if dx < 0 || dx >= size || dy < 0 || dy >= size {
+		return 0, false
+	}
+	return dx*size + dy, true
+}
+
+func (fov *FieldOfView) set(x, y int) {
+	i, ok := fov.index(x, y)
+	if ok {
+		fov.visible[i] = true
+	}
+}
+
+func (fov *FieldOfView) Visible(x, y int) bool {
+	i, ok := fov.index(x, y)
+	return ok && fov.visible[i]
+}
+
+// Each calls f for every visible tile.
+func (fov *FieldOfView) Each(f func(x, y int)) {
+	size := 2*fov.Radius + 1
+	for i, visible := range fov.visible {
+		if visible {
+			f(fov.OriginX-fov.Radius+i/size, fov.OriginY-fov.Radius+i%size)
+		}
+	}
+}
+
+// Octant transforms for shadowcasting
+var multipliers = [4][8]int{
+	{1, 0, 0, -1, -1, 0, 0, 1},
+	{0, 1, -1, 0, 0, -1, 1, 0},
+	{0, 1, 1, 0, 0, -1, -1, 0},
+	{1, 0, 0, 1, -1, 0, 0, -1},
+}
+
+// ComputeFieldOfView finds every tile visible from the origin tile within radius tiles
+// using recursive shadowcasting. Blocking tiles are visible themselves. Nothing, not even
+// the origin, is visible with a negative radius.
+func ComputeFieldOfView(tmap tilemap.Map, originX, originY, radius int, blocks BlockFunc) *FieldOfView {
+	if radius < 0 {
+		return &FieldOfView{OriginX: originX, OriginY: originY, Radius: radius}
+	}
+	size := 2*radius + 1
+	fov := &FieldOfView{
+		OriginX: originX,
+		OriginY: originY,
+		Radius:  radius,
+		visible: make([]bool, size*size),
+	}
+	fov.set(originX, originY)
+
+	caster := shadowcaster{tmap: tmap, fov: fov, blocks: blocks}
+	for octant := 0; octant < 8; octant++ {
+		caster.cast(1, 1.0, 0.0,
+			multipliers[0][octant], multipliers[1][octant],
+			multipliers[2][octant], multipliers[3][octant])
+	}
+	return fov
+}
+
+type shadowcaster struct {
+	tmap   tilemap.Map
+	fov    *FieldOfView
+	blocks BlockFunc
+}
+
+func (caster *shadowcaster) blocked(x, y int) bool {
+	_, stop := blocked(caster.tmap, x, y, caster.blocks)
+	return stop
+}
+
+func (caster *shadowcaster) cast(row int, start, end float64, xx, xy, yx, yy int) {
+	if start < end {
+		return
+	}
+
+	fov := caster.fov
+	radiusSquared := fov.Radius * fov.Radius
+	newStart := 0.0
+	for j := row; j <= fov.Radius; j++ {
+		dx, dy := -j-1, -j
+		blocked := false
+		for dx <= 0 {
+			dx++
+			x := fov.OriginX + dx*xx + dy*xy
+			y := fov.OriginY + dx*yx + dy*yy
+			leftSlope := (float64(dx) - 0.5) / (float64(dy) + 0.5)
+			rightSlope := (float64(dx) + 0.5) / (float64(dy) - 0.5)
+			if start < rightSlope {
+				continue
+			} else if end > leftSlope {
+				break
+			}
+
+			if dx*dx+dy*dy <= radiusSquared {
+				fov.set(x, y)
+			}
+
+			if blocked {
+				if caster.blocked(x, y) {
+					newStart = rightSlope
+					continue
+				}
+				blocked = false
+				start = newStart
+			} else if caster.blocked(x, y) && j < fov.Radius {
+				blocked = true
+				caster.cast(j+1, start, leftSlope, xx, xy, yx, yy)
+				newStart = rightSlope
+			}
+		}
+		if blocked {
+			break
+		}
+	}
+}
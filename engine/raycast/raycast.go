@@ -0,0 +1,92 @@
+package raycast
+
+import (
+	"gommo/engine/tilemap"
+	"math"
+)
+
+// BlockFunc reports whether a ground tile stops rays. Tiles outside the map and cells
+// blocked on the collision layer always block, whatever it says.
+type BlockFunc func(tile tilemap.Tile) bool
+
+// blocked reports whether the cell at a tile coordinate stops rays, along with its ground tile.
+func blocked(tmap tilemap.Map, x int, y int, blocks BlockFunc) (tilemap.Tile, bool) {
+	tile, ok := tmap.Get(x, y)
+	return tile, !ok || tmap.Collides(x, y) || blocks(tile)
+}
+
+type Hit struct {
+	TileX, TileY int
+	Tile         tilemap.Tile
+	X, Y         float64 // World position where the ray entered the blocking tile
+	Distance     float64
+}
+
+// Cast walks the tiles crossed by the segment from (x0, y0) to (x1, y1) in world space
+// and returns the first blocking tile. Tiles are centered on x*TileSize, matching how
+// they are drawn.
+func Cast(tmap tilemap.Map, x0, y0, x1, y1 float64, blocks BlockFunc) (Hit, bool) {
+	tileSize := tmap.TileBounds(0, 0).Width()
+	// Grid space, where tile x spans [x, x+1)
+	gx0, gy0 := x0/tileSize+0.5, y0/tileSize+0.5
+	gx1, gy1 := x1/tileSize+0.5, y1/tileSize+0.5
+
+	dirX, dirY := gx1-gx0, gy1-gy0
+	length := math.Hypot(dirX, dirY)
+
+	tileX, tileY := int(math.Floor(gx0)), int(math.Floor(gy0))
+	endX, endY := int(math.Floor(gx1)), int(math.Floor(gy1))
+
+	stepX, tMaxX, tDeltaX := setupAxis(gx0, dirX)
+	stepY, tMaxY, tDeltaY := setupAxis(gy0, dirY)
+
+	t := 0.0
+	for {
+		tile, stop := blocked(tmap, tileX, tileY, blocks)
+		if stop {
+			return Hit{
+				TileX:    tileX,
+				TileY:    tileY,
+				Tile:     tile,
+				X:        x0 + dirX*t*tileSize,
+				Y:        y0 + dirY*t*tileSize,
+				Distance: length * t * tileSize,
+			}, true
+		}
+
+		if tileX == endX && tileY == endY {
+			return Hit{}, false
+		}
+
+		if tMaxX < tMaxY {
+			t = tMaxX
+			tMaxX += tDeltaX
+			tileX += stepX
+		} else {
+			t = tMaxY
+			tMaxY += tDeltaY
+			tileY += stepY
+		}
+		if t > 1 {
+			return Hit{}, false
+		}
+	}
+}
+
+// setupAxis returns the step direction, the ray parameter of the first tile boundary
+// and the parameter distance between boundaries along one axis.
+func setupAxis(origin, dir float64) (int, float64, float64) {
+	if dir > 0 {
+		return 1, (math.Floor(origin) + 1 - origin) / dir, 1 / dir
+	}
+	if dir < 0 {
+		return -1, (origin - math.Floor(origin)) / -dir, 1 / -dir
+	}
+	return 0, math.Inf(1), math.Inf(1)
+}
+
+// LineOfSight reports whether nothing blocks the segment between two world positions.
+func LineOfSight(tmap tilemap.Map, x0, y0, x1, y1 float64, blocks BlockFunc) bool {
+	_, hit := Cast(tmap, x0, y0, x1, y1, blocks)
+	return !hit
+}
@@ -30,3 +30,11 @@ func (camera *Camera) Update() {
 func (camera *Camera) Matrix() pixel.Matrix {
 	return camera.matrix
 }
+
+// View returns the area of the world the camera shows.
+func (camera *Camera) View() pixel.Rect {
+	bounds := camera.window.Bounds()
+	min := camera.matrix.Unproject(bounds.Min)
+	max := camera.matrix.Unproject(bounds.Max)
+	return pixel.R(min.X, min.Y, max.X, max.Y).Norm()
+}
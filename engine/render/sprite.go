@@ -5,6 +5,8 @@ import (
 	"github.com/faiface/pixel/pixelgl"
 	"gommo/engine/ecs"
 	"gommo/engine/physics"
+	"sort"
+	"time"
 )
 
 type Sprite struct {
@@ -22,19 +24,63 @@ var ArrowKeybinds = Keybinds{Up: pixelgl.KeyUp, Down: pixelgl.KeyDown, Left: pix
 
 func (t *Keybinds) ComponentSet(val interface{}) { *t = val.(Keybinds) }
 
-func DrawSprites(win *pixelgl.Window, engine *ecs.Engine) {
+// InterpolationSettings control how far in the past remote entities are drawn, so there is
+// usually a newer snapshot to interpolate towards.
+type InterpolationSettings struct {
+	Delay            time.Duration
+	MaxExtrapolation time.Duration
+}
+
+var DefaultInterpolationSettings = InterpolationSettings{Delay: 100 * time.Millisecond, MaxExtrapolation: 250 * time.Millisecond}
+
+// DrawSprites draws entities back to front, so sprites lower on the screen overlap those behind them.
+func DrawSprites(win *pixelgl.Window, engine *ecs.Engine, interpolation InterpolationSettings) {
+	renderTime := time.Now().Add(-interpolation.Delay)
+
+	type drawable struct {
+		sprite Sprite
+		pos    pixel.Vec
+	}
+	drawables := []drawable{}
+
 	ecs.Each(engine, Sprite{}, func(id ecs.Id, a interface{}) {
 		sprite := a.(Sprite)
 
 		transform := physics.Transform{}
-		ok := ecs.Read(engine, id, &transform)
-		if !ok {
-			return
+		snapshots := physics.Interpolation{}
+		if ecs.Read(engine, id, &snapshots) {
+			sampled, ok := snapshots.Sample(renderTime, interpolation.MaxExtrapolation)
+			if !ok {
+				return
+			}
+			transform = sampled
+		} else {
+			ok := ecs.Read(engine, id, &transform)
+			if !ok {
+				return
+			}
 		}
 
 		pos := pixel.V(transform.X, transform.Y)
-		sprite.Draw(win, pixel.IM.Scaled(pixel.ZV, 2.0).Moved(pos))
+
+		prediction := physics.Prediction{}
+		if ecs.Read(engine, id, &prediction) {
+			pos = pos.Add(pixel.V(prediction.OffsetX, prediction.OffsetY))
+		}
+
+		drawables = append(drawables, drawable{sprite, pos})
 	})
+
+	sort.SliceStable(drawables, func(i, j int) bool {
+		if drawables[i].pos.Y != drawables[j].pos.Y {
+			return drawables[i].pos.Y > drawables[j].pos.Y
+		}
+		return drawables[i].pos.X < drawables[j].pos.X
+	})
+
+	for _, d := range drawables {
+		d.sprite.Draw(win, pixel.IM.Scaled(pixel.ZV, 2.0).Moved(d.pos))
+	}
 }
 
 func CaptureInput(win *pixelgl.Window, engine *ecs.Engine) {
@@ -5,46 +5,225 @@ import (
 	"github.com/faiface/pixel/pixelgl"
 	"gommo/engine/asset"
 	"gommo/engine/tilemap"
+	"sort"
 )
 
+// Tiles are batched in square regions so an edit only rebatches the regions it touches,
+// and only regions near the camera need to be batched at all.
+const regionSize = 128
+
+// Regions are batched once they are within batchMargin regions of the view and dropped
+// once they are further than dropMargin, so panning back and forth doesn't rebatch.
+const (
+	batchMargin = 1
+	dropMargin  = 2
+)
+
+type region struct {
+	X, Y int
+}
+
+// regionBatches holds everything drawn for a region, and the world area it covers so
+// regions outside the camera can be skipped.
+type regionBatches struct {
+	layers  [tilemap.LayerCount]*pixel.Batch
+	statics *pixel.Batch
+	bounds  pixel.Rect
+}
+
+// StaticSprite is a sprite that never moves, like a tree, drawn as part of the region it is in
+// instead of as an entity.
+type StaticSprite struct {
+	Sprite   *pixel.Sprite
+	Position pixel.Vec
+}
+
+// StaticFunc returns the static sprites standing on the tiles from (minX, minY) up to but
+// not including (maxX, maxY). It is called whenever a region is batched, so it must return
+// the same sprites every time.
+type StaticFunc func(minX, minY, maxX, maxY int) []StaticSprite
+
 type TilemapRender struct {
 	spritesheet  *asset.Spritesheet
-	batch        *pixel.Batch
+	regions      map[region]*regionBatches
+	statics      StaticFunc
+	dirty        map[region]bool
 	tileToSprite map[tilemap.TileType]*pixel.Sprite
 }
 
-func NewTilemapRender(spritesheet *asset.Spritesheet, tileToSprite map[tilemap.TileType]*pixel.Sprite) *TilemapRender {
+// NewTilemapRender draws tiles and, if statics isn't nil, the static sprites it returns.
+func NewTilemapRender(spritesheet *asset.Spritesheet, tileToSprite map[tilemap.TileType]*pixel.Sprite, statics StaticFunc) *TilemapRender {
 	return &TilemapRender{
 		spritesheet:  spritesheet,
-		batch:        pixel.NewBatch(&pixel.TrianglesData{}, spritesheet.Picture()),
+		regions:      make(map[region]*regionBatches),
+		statics:      statics,
+		dirty:        make(map[region]bool),
 		tileToSprite: tileToSprite,
 	}
 }
 
 func (tilemapRender TilemapRender) Clear() {
-	tilemapRender.batch.Clear()
+	for _, batches := range tilemapRender.regions {
+		for _, batch := range batches.layers {
+			batch.Clear()
+		}
+		batches.statics.Clear()
+	}
 }
 
-func (tilemapRender TilemapRender) Batch(tilemap *tilemap.Tilemap) {
-	for x := 0; x < tilemap.Width(); x++ {
-		for y := 0; y < tilemap.Height(); y++ {
-			tile, ok := tilemap.Get(x, y)
-			if !ok {
-				continue
+// MarkDirty flags the regions touched by a change, to be rebatched by Update.
+func (tilemapRender TilemapRender) MarkDirty(change tilemap.Change) {
+	for _, cell := range change.Cells {
+		tilemapRender.dirty[region{cell.X / regionSize, cell.Y / regionSize}] = true
+	}
+}
+
+// Update batches the regions around view that aren't batched yet or were edited, and drops
+// the ones that have gone far out of view.
+func (tilemapRender TilemapRender) Update(tmap tilemap.Map, view pixel.Rect) {
+	for r := range tilemapRender.regions {
+		if !regionRect(tmap, r).Intersects(grow(view, tmap, dropMargin)) {
+			delete(tilemapRender.regions, r)
+		}
+	}
+
+	for r := range tilemapRender.dirty {
+		_, batched := tilemapRender.regions[r]
+		if batched {
+			tilemapRender.batchRegion(tmap, r)
+		}
+		delete(tilemapRender.dirty, r)
+	}
+
+	near := grow(view, tmap, batchMargin)
+	minX, minY := tmap.WorldToTile(near.Min.X, near.Min.Y)
+	maxX, maxY := tmap.WorldToTile(near.Max.X, near.Max.Y)
+	for x := maxInt(minX, 0) / regionSize; x <= minInt(maxX, tmap.Width()-1)/regionSize; x++ {
+		for y := maxInt(minY, 0) / regionSize; y <= minInt(maxY, tmap.Height()-1)/regionSize; y++ {
+			r := region{x, y}
+			_, batched := tilemapRender.regions[r]
+			if !batched {
+				tilemapRender.batchRegion(tmap, r)
 			}
-			position := pixel.V(float64(x*tilemap.TileSize), float64(y*tilemap.TileSize))
+		}
+	}
+}
 
-			sprite, ok := tilemapRender.tileToSprite[tile.Type]
-			if !ok {
-				panic("unable to find TileType")
+// regionRect is the world area covered by the tiles of a region.
+func regionRect(tmap tilemap.Map, r region) pixel.Rect {
+	min := tmap.TileBounds(r.X*regionSize, r.Y*regionSize)
+	max := tmap.TileBounds((r.X+1)*regionSize-1, (r.Y+1)*regionSize-1)
+	return pixel.R(min.MinX, min.MinY, max.MaxX, max.MaxY)
+}
+
+// grow extends a rect by a number of regions on every side.
+func grow(rect pixel.Rect, tmap tilemap.Map, regions int) pixel.Rect {
+	margin := float64(regions*regionSize) * tmap.TileBounds(0, 0).Width()
+	return pixel.R(rect.Min.X-margin, rect.Min.Y-margin, rect.Max.X+margin, rect.Max.Y+margin)
+}
+
+func (tilemapRender TilemapRender) batchRegion(tmap tilemap.Map, r region) {
+	batches, ok := tilemapRender.regions[r]
+	if !ok {
+		batches = &regionBatches{}
+		for i := range batches.layers {
+			batches.layers[i] = pixel.NewBatch(&pixel.TrianglesData{}, tilemapRender.spritesheet.Picture())
+		}
+		batches.statics = pixel.NewBatch(&pixel.TrianglesData{}, tilemapRender.spritesheet.Picture())
+		tilemapRender.regions[r] = batches
+	}
+
+	batches.bounds = regionRect(tmap, r)
+
+	for layer := tilemap.Layer(0); layer < tilemap.LayerCount; layer++ {
+		batches.layers[layer].Clear()
+		if layer == tilemap.CollisionLayer || !tmap.HasLayer(layer) {
+			continue
+		}
+
+		for x := r.X * regionSize; x < (r.X+1)*regionSize && x < tmap.Width(); x++ {
+			for y := r.Y * regionSize; y < (r.Y+1)*regionSize && y < tmap.Height(); y++ {
+				tile, ok := tmap.GetLayer(layer, x, y)
+				if !ok || tile.Type == tilemap.EmptyTile {
+					continue
+				}
+				position := pixel.V(tmap.TileToWorld(x, y))
+
+				sprite, ok := tilemapRender.tileToSprite[tile.Type]
+				if !ok {
+					panic("unable to find TileType")
+				}
+
+				matrix := pixel.IM.Moved(position)
+				sprite.Draw(batches.layers[layer], matrix)
 			}
+		}
+	}
+
+	batches.statics.Clear()
+	if tilemapRender.statics == nil {
+		return
+	}
 
-			matrix := pixel.IM.Moved(position)
-			sprite.Draw(tilemapRender.batch, matrix)
+	// Back to front like DrawSprites, so statics in a region overlap correctly
+	statics := tilemapRender.statics(r.X*regionSize, r.Y*regionSize, (r.X+1)*regionSize, (r.Y+1)*regionSize)
+	sort.SliceStable(statics, func(i, j int) bool {
+		if statics[i].Position.Y != statics[j].Position.Y {
+			return statics[i].Position.Y > statics[j].Position.Y
 		}
+		return statics[i].Position.X < statics[j].Position.X
+	})
+	for _, static := range statics {
+		matrix := pixel.IM.Scaled(pixel.ZV, 2.0).Moved(static.Position)
+		static.Sprite.Draw(batches.statics, matrix)
+
+		// Sprites can hang over the edge of their region
+		frame := static.Sprite.Frame()
+		size := frame.Size().Scaled(2.0)
+		batches.bounds = batches.bounds.Union(pixel.Rect{
+			Min: static.Position.Sub(size.Scaled(0.5)),
+			Max: static.Position.Add(size.Scaled(0.5)),
+		})
 	}
 }
 
-func (tilemapRender *TilemapRender) Draw(window *pixelgl.Window) {
-	tilemapRender.batch.Draw(window)
+func (tilemapRender *TilemapRender) drawVisible(window *pixelgl.Window, view pixel.Rect, draw func(batches *regionBatches)) {
+	for _, batches := range tilemapRender.regions {
+		if batches.bounds.Intersects(view) {
+			draw(batches)
+		}
+	}
+}
+
+// DrawGround draws the layers and static sprites that belong underneath entities, skipping
+// regions outside view.
+func (tilemapRender *TilemapRender) DrawGround(window *pixelgl.Window, view pixel.Rect) {
+	tilemapRender.drawVisible(window, view, func(batches *regionBatches) {
+		batches.layers[tilemap.GroundLayer].Draw(window)
+		batches.layers[tilemap.DecorationLayer].Draw(window)
+	})
+	tilemapRender.drawVisible(window, view, func(batches *regionBatches) {
+		batches.statics.Draw(window)
+	})
+}
+
+// DrawOverlay draws the layers that belong on top of entities, skipping regions outside view.
+func (tilemapRender *TilemapRender) DrawOverlay(window *pixelgl.Window, view pixel.Rect) {
+	tilemapRender.drawVisible(window, view, func(batches *regionBatches) {
+		batches.layers[tilemap.OverlayLayer].Draw(window)
+	})
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
 }
@@ -0,0 +1,177 @@
+package tiled
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+)
+
+type jsonMap struct {
+	Width       int           `json:"width"`
+	Height      int           `json:"height"`
+	TileWidth   int           `json:"tilewidth"`
+	TileHeight  int           `json:"tileheight"`
+	Orientation string        `json:"orientation"`
+	Infinite    bool          `json:"infinite"`
+	Layers      []jsonLayer   `json:"layers"`
+	Tilesets    []jsonTileset `json:"tilesets"`
+}
+
+type jsonLayer struct {
+	Type        string          `json:"type"`
+	Name        string          `json:"name"`
+	Data        json.RawMessage `json:"data"`
+	Encoding    string          `json:"encoding"`
+	Compression string          `json:"compression"`
+	Objects     []jsonObject    `json:"objects"`
+	Layers      []jsonLayer     `json:"layers"`
+	Properties  []jsonProperty  `json:"properties"`
+}
+
+type jsonObject struct {
+	Name       string         `json:"name"`
+	Type       string         `json:"type"`
+	Class      string         `json:"class"`
+	X          float64        `json:"x"`
+	Y          float64        `json:"y"`
+	Width      float64        `json:"width"`
+	Height     float64        `json:"height"`
+	Gid        uint32         `json:"gid"`
+	Properties []jsonProperty `json:"properties"`
+}
+
+type jsonTileset struct {
+	FirstGid uint32     `json:"firstgid"`
+	Source   string     `json:"source"`
+	Tiles    []jsonTile `json:"tiles"`
+}
+
+type jsonTile struct {
+	Id         uint32         `json:"id"`
+	Image      string         `json:"image"`
+	Properties []jsonProperty `json:"properties"`
+}
+
+type jsonProperty struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+}
+
+func jsonProperties(properties []jsonProperty) map[string]string {
+	ret := make(map[string]string)
+	for _, p := range properties {
+		ret[p.Name] = fmt.Sprint(p.Value)
+	}
+	return ret
+}
+
+func (loader loader) loadJSON(data []byte) (*Map, error) {
+	m := jsonMap{}
+	err := json.Unmarshal(data, &m)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := newBuilder(loader.resolve, m.Width, m.Height, m.TileWidth, m.TileHeight, m.Orientation, m.Infinite)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ts := range m.Tilesets {
+		if ts.Source != "" {
+			frames, err := loader.loadExternalTileset(ts.Source)
+			if err != nil {
+				return nil, err
+			}
+			b.addTileset(ts.FirstGid, frames)
+			continue
+		}
+		b.addTileset(ts.FirstGid, jsonFrames(ts))
+	}
+
+	err = addJSONLayers(b, m.Layers)
+	if err != nil {
+		return nil, err
+	}
+	return b.build()
+}
+
+func jsonFrames(ts jsonTileset) map[uint32]string {
+	frames := make(map[uint32]string)
+	for _, tile := range ts.Tiles {
+		frame := frameName(tile.Image, jsonProperties(tile.Properties))
+		if frame != "" {
+			frames[tile.Id] = frame
+		}
+	}
+	return frames
+}
+
+func addJSONLayers(b *builder, layers []jsonLayer) error {
+	for _, layer := range layers {
+		properties := jsonProperties(layer.Properties)
+		switch layer.Type {
+		case "tilelayer":
+			gids, err := jsonLayerData(layer, b.width*b.height)
+			if err != nil {
+				return err
+			}
+			tilemapLayer, err := layerFor(layer.Name, properties)
+			if err != nil {
+				return err
+			}
+			err = b.addTiles(tilemapLayer, gids)
+			if err != nil {
+				return err
+			}
+		case "objectgroup":
+			for _, obj := range layer.Objects {
+				objectType := obj.Type
+				if objectType == "" {
+					objectType = obj.Class
+				}
+				b.addObject(obj.Name, objectType, obj.X, obj.Y, obj.Width, obj.Height, obj.Gid, jsonProperties(obj.Properties))
+			}
+		case "group":
+			err := addJSONLayers(b, layer.Layers)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func jsonLayerData(layer jsonLayer, tiles int) ([]uint32, error) {
+	if layer.Encoding == "base64" {
+		encoded := ""
+		err := json.Unmarshal(layer.Data, &encoded)
+		if err != nil {
+			return nil, err
+		}
+		return decodeData(layer.Encoding, layer.Compression, encoded, tiles)
+	}
+
+	gids := []uint32{}
+	err := json.Unmarshal(layer.Data, &gids)
+	return gids, err
+}
+
+func (loader loader) loadExternalTileset(source string) (map[uint32]string, error) {
+	tilesetPath := path.Join(loader.dir, source)
+	data, err := readFile(loader.fsys, tilesetPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if path.Ext(tilesetPath) == ".tsx" {
+		return tsxFrames(data)
+	}
+
+	ts := jsonTileset{}
+	err = json.Unmarshal(data, &ts)
+	if err != nil {
+		return nil, err
+	}
+	return jsonFrames(ts), nil
+}
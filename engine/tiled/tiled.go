@@ -0,0 +1,302 @@
+package tiled
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"gommo/engine/tilemap"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"path"
+	"strings"
+)
+
+const (
+	flippedHorizontally = 0x80000000
+	flippedVertically   = 0x40000000
+	flippedDiagonally   = 0x20000000
+	rotatedHexagonal    = 0x10000000
+	gidMask             = ^uint32(flippedHorizontally | flippedVertically | flippedDiagonally | rotatedHexagonal)
+)
+
+// Resolver maps a spritesheet frame name to the TileType that uses it.
+type Resolver func(frame string) (tilemap.TileType, bool)
+
+// Spawn is an object placed on an object layer. X and Y are world coordinates relative
+// to the bottom left tile of the map, with tiles centered on x*TileSize like Tilemap.
+type Spawn struct {
+	Name       string
+	Type       string
+	X, Y       float64
+	Properties map[string]string
+}
+
+type Map struct {
+	Tilemap *tilemap.Tilemap
+	Spawns  []Spawn
+}
+
+// Load imports a Tiled map saved as JSON (.json, .tmj) or XML (.tmx). Cells without a
+// tile are EmptyTile on every layer, including the ground. Tiles resolve to frame names
+// through their "frame" property, or else the file name of their image.
+func Load(fsys fs.FS, mapPath string, resolve Resolver) (*Map, error) {
+	data, err := readFile(fsys, mapPath)
+	if err != nil {
+		return nil, err
+	}
+
+	loader := loader{fsys: fsys, dir: path.Dir(mapPath), resolve: resolve}
+	switch strings.ToLower(path.Ext(mapPath)) {
+	case ".json", ".tmj":
+		return loader.loadJSON(data)
+	case ".tmx":
+		return loader.loadTMX(data)
+	}
+	return nil, fmt.Errorf("unsupported tiled map format: %s", mapPath)
+}
+
+func readFile(fsys fs.FS, name string) ([]byte, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ioutil.ReadAll(file)
+}
+
+type loader struct {
+	fsys    fs.FS
+	dir     string
+	resolve Resolver
+}
+
+type tileset struct {
+	firstGid uint32
+	frames   map[uint32]string
+}
+
+type builder struct {
+	resolve  Resolver
+	width    int
+	height   int
+	tileSize float64
+	tilesets []tileset
+	layers   [tilemap.LayerCount][][]tilemap.Tile
+	spawns   []Spawn
+}
+
+func newBuilder(resolve Resolver, width, height, tileWidth, tileHeight int, orientation string, infinite bool) (*builder, error) {
+	if orientation != "" && orientation != "orthogonal" {
+		return nil, fmt.Errorf("unsupported tiled orientation: %s", orientation)
+	}
+	if infinite {
+		return nil, fmt.Errorf("infinite tiled maps are not supported")
+	}
+	if width <= 0 || height <= 0 || tileWidth <= 0 || tileWidth != tileHeight {
+		return nil, fmt.Errorf("invalid tiled map size %dx%d with %dx%d tiles", width, height, tileWidth, tileHeight)
+	}
+	return &builder{
+		resolve:  resolve,
+		width:    width,
+		height:   height,
+		tileSize: float64(tileWidth),
+	}, nil
+}
+
+func (b *builder) addTileset(firstGid uint32, frames map[uint32]string) {
+	b.tilesets = append(b.tilesets, tileset{firstGid, frames})
+}
+
+func (b *builder) frame(gid uint32) (string, error) {
+	var found *tileset
+	for i := range b.tilesets {
+		if b.tilesets[i].firstGid <= gid && (found == nil || b.tilesets[i].firstGid > found.firstGid) {
+			found = &b.tilesets[i]
+		}
+	}
+	if found == nil {
+		return "", fmt.Errorf("no tileset for gid %d", gid)
+	}
+	frame, ok := found.frames[gid-found.firstGid]
+	if !ok {
+		return "", fmt.Errorf("tile gid %d has no frame name or image", gid)
+	}
+	return frame, nil
+}
+
+// layer picks the tilemap layer from a "layer" property, or else the Tiled layer name.
+func layerFor(name string, properties map[string]string) (tilemap.Layer, error) {
+	if value, ok := properties["layer"]; ok {
+		name = value
+	}
+	layer, ok := tilemap.LayerByName(strings.ToLower(name))
+	if !ok {
+		return 0, fmt.Errorf("tiled layer %q doesn't match a tilemap layer", name)
+	}
+	return layer, nil
+}
+
+// addTiles places row major gids, with row 0 at the top as Tiled stores them.
+func (b *builder) addTiles(layer tilemap.Layer, gids []uint32) error {
+	if len(gids) != b.width*b.height {
+		return fmt.Errorf("tiled layer %s has %d tiles, expected %d", layer, len(gids), b.width*b.height)
+	}
+
+	tiles := b.layers[layer]
+	if tiles == nil {
+		tiles = make([][]tilemap.Tile, b.width)
+		for x := range tiles {
+			tiles[x] = make([]tilemap.Tile, b.height)
+			for y := range tiles[x] {
+				tiles[x][y] = tilemap.Tile{Type: tilemap.EmptyTile}
+			}
+		}
+		b.layers[layer] = tiles
+	}
+
+	for i, gid := range gids {
+		gid &= gidMask
+		if gid == 0 {
+			continue
+		}
+		frame, err := b.frame(gid)
+		if err != nil {
+			return err
+		}
+		tileType, ok := b.resolve(frame)
+		if !ok {
+			return fmt.Errorf("no tile type uses frame %q", frame)
+		}
+		x, y := i%b.width, b.height-1-i/b.width
+		tiles[x][y] = tilemap.Tile{Type: tileType}
+	}
+	return nil
+}
+
+// addObject converts a Tiled object, whose position is in pixels from the top left
+// corner of the map, into a spawn at its center. Tile objects are anchored at their
+// bottom left corner instead of the top left.
+func (b *builder) addObject(name, objectType string, x, y, width, height float64, gid uint32, properties map[string]string) {
+	centerX := x + width/2
+	centerY := y + height/2
+	if gid != 0 {
+		centerY = y - height/2
+	}
+
+	tileX := centerX/b.tileSize - 0.5
+	tileY := float64(b.height) - centerY/b.tileSize - 0.5
+	b.spawns = append(b.spawns, Spawn{
+		Name:       name,
+		Type:       objectType,
+		X:          tileX * b.tileSize,
+		Y:          tileY * b.tileSize,
+		Properties: properties,
+	})
+}
+
+func (b *builder) build() (*Map, error) {
+	ground := b.layers[tilemap.GroundLayer]
+	if ground == nil {
+		ground = make([][]tilemap.Tile, b.width)
+		for x := range ground {
+			ground[x] = make([]tilemap.Tile, b.height)
+			for y := range ground[x] {
+				ground[x][y] = tilemap.Tile{Type: tilemap.EmptyTile}
+			}
+		}
+	}
+
+	tmap := tilemap.New(ground, int(b.tileSize))
+	for layer := tilemap.DecorationLayer; layer < tilemap.LayerCount; layer++ {
+		if b.layers[layer] != nil {
+			tmap.SetLayer(layer, b.layers[layer])
+		}
+	}
+	return &Map{Tilemap: tmap, Spawns: b.spawns}, nil
+}
+
+// decodeData handles the csv and base64 encodings with optional zlib or gzip compression.
+// Compressed data is never expanded past the size of a layer of tiles.
+func decodeData(encoding, compression, data string, tiles int) ([]uint32, error) {
+	switch encoding {
+	case "csv":
+		gids := []uint32{}
+		for _, field := range strings.Split(data, ",") {
+			field = strings.TrimSpace(field)
+			if field == "" {
+				continue
+			}
+			gid := uint32(0)
+			_, err := fmt.Sscan(field, &gid)
+			if err != nil {
+				return nil, fmt.Errorf("invalid csv tile %q", field)
+			}
+			gids = append(gids, gid)
+		}
+		return gids, nil
+	case "base64":
+		raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(data))
+		if err != nil {
+			return nil, err
+		}
+		raw, err = decompress(compression, raw, tiles*4)
+		if err != nil {
+			return nil, err
+		}
+		if len(raw)%4 != 0 {
+			return nil, fmt.Errorf("invalid base64 tile data length %d", len(raw))
+		}
+		gids := make([]uint32, len(raw)/4)
+		for i := range gids {
+			gids[i] = binary.LittleEndian.Uint32(raw[i*4:])
+		}
+		return gids, nil
+	}
+	return nil, fmt.Errorf("unsupported tile data encoding: %q", encoding)
+}
+
+// decompress fails once the data expands past limit bytes, so a small layer can't expand
+// into gigabytes.
+func decompress(compression string, raw []byte, limit int) ([]byte, error) {
+	var reader io.ReadCloser
+	var err error
+	switch compression {
+	case "":
+		return raw, nil
+	case "zlib":
+		reader, err = zlib.NewReader(bytes.NewReader(raw))
+	case "gzip":
+		reader, err = gzip.NewReader(bytes.NewReader(raw))
+	default:
+		return nil, fmt.Errorf("unsupported tile data compression: %s", compression)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	data, err := ioutil.ReadAll(io.LimitReader(reader, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > limit {
+		return nil, fmt.Errorf("tile data expands past %d bytes", limit)
+	}
+	return data, nil
+}
+
+// frameName uses the frame property if set, or else the file name of the tile image.
+func frameName(image string, properties map[string]string) string {
+	if frame, ok := properties["frame"]; ok {
+		return frame
+	}
+	if image == "" {
+		return ""
+	}
+	return path.Base(image)
+}
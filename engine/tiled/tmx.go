@@ -0,0 +1,180 @@
+package tiled
+
+import "encoding/xml"
+
+type tmxMap struct {
+	Width       int             `xml:"width,attr"`
+	Height      int             `xml:"height,attr"`
+	TileWidth   int             `xml:"tilewidth,attr"`
+	TileHeight  int             `xml:"tileheight,attr"`
+	Orientation string          `xml:"orientation,attr"`
+	Infinite    int             `xml:"infinite,attr"`
+	Tilesets    []tmxTileset    `xml:"tileset"`
+	Layers      []tmxLayerGroup `xml:",any"`
+}
+
+// tmxLayerGroup holds any child element of a map or group, since the order of tile
+// layers, object layers and groups matters.
+type tmxLayerGroup struct {
+	XMLName    xml.Name
+	Name       string          `xml:"name,attr"`
+	Data       tmxData         `xml:"data"`
+	Objects    []tmxObject     `xml:"object"`
+	Layers     []tmxLayerGroup `xml:",any"`
+	Properties []tmxProperty   `xml:"properties>property"`
+}
+
+type tmxData struct {
+	Encoding    string    `xml:"encoding,attr"`
+	Compression string    `xml:"compression,attr"`
+	Tiles       []tmxTile `xml:"tile"`
+	Content     string    `xml:",chardata"`
+}
+
+type tmxTile struct {
+	Gid uint32 `xml:"gid,attr"`
+}
+
+type tmxObject struct {
+	Name       string        `xml:"name,attr"`
+	Type       string        `xml:"type,attr"`
+	Class      string        `xml:"class,attr"`
+	X          float64       `xml:"x,attr"`
+	Y          float64       `xml:"y,attr"`
+	Width      float64       `xml:"width,attr"`
+	Height     float64       `xml:"height,attr"`
+	Gid        uint32        `xml:"gid,attr"`
+	Properties []tmxProperty `xml:"properties>property"`
+}
+
+type tmxTileset struct {
+	FirstGid uint32           `xml:"firstgid,attr"`
+	Source   string           `xml:"source,attr"`
+	Tiles    []tmxTilesetTile `xml:"tile"`
+}
+
+type tmxTilesetTile struct {
+	Id         uint32        `xml:"id,attr"`
+	Image      tmxImage      `xml:"image"`
+	Properties []tmxProperty `xml:"properties>property"`
+}
+
+type tmxImage struct {
+	Source string `xml:"source,attr"`
+}
+
+type tmxProperty struct {
+	Name    string `xml:"name,attr"`
+	Value   string `xml:"value,attr"`
+	Content string `xml:",chardata"`
+}
+
+func tmxProperties(properties []tmxProperty) map[string]string {
+	ret := make(map[string]string)
+	for _, p := range properties {
+		// Multiline string properties are stored as content instead of an attribute
+		if p.Value == "" {
+			ret[p.Name] = p.Content
+		} else {
+			ret[p.Name] = p.Value
+		}
+	}
+	return ret
+}
+
+func (loader loader) loadTMX(data []byte) (*Map, error) {
+	m := tmxMap{}
+	err := xml.Unmarshal(data, &m)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := newBuilder(loader.resolve, m.Width, m.Height, m.TileWidth, m.TileHeight, m.Orientation, m.Infinite != 0)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ts := range m.Tilesets {
+		if ts.Source != "" {
+			frames, err := loader.loadExternalTileset(ts.Source)
+			if err != nil {
+				return nil, err
+			}
+			b.addTileset(ts.FirstGid, frames)
+			continue
+		}
+		b.addTileset(ts.FirstGid, tmxFrames(ts))
+	}
+
+	err = addTMXLayers(b, m.Layers)
+	if err != nil {
+		return nil, err
+	}
+	return b.build()
+}
+
+func tmxFrames(ts tmxTileset) map[uint32]string {
+	frames := make(map[uint32]string)
+	for _, tile := range ts.Tiles {
+		frame := frameName(tile.Image.Source, tmxProperties(tile.Properties))
+		if frame != "" {
+			frames[tile.Id] = frame
+		}
+	}
+	return frames
+}
+
+func tsxFrames(data []byte) (map[uint32]string, error) {
+	ts := tmxTileset{}
+	err := xml.Unmarshal(data, &ts)
+	if err != nil {
+		return nil, err
+	}
+	return tmxFrames(ts), nil
+}
+
+func addTMXLayers(b *builder, layers []tmxLayerGroup) error {
+	for _, layer := range layers {
+		properties := tmxProperties(layer.Properties)
+		switch layer.XMLName.Local {
+		case "layer":
+			gids, err := tmxLayerData(layer.Data, b.width*b.height)
+			if err != nil {
+				return err
+			}
+			tilemapLayer, err := layerFor(layer.Name, properties)
+			if err != nil {
+				return err
+			}
+			err = b.addTiles(tilemapLayer, gids)
+			if err != nil {
+				return err
+			}
+		case "objectgroup":
+			for _, obj := range layer.Objects {
+				objectType := obj.Type
+				if objectType == "" {
+					objectType = obj.Class
+				}
+				b.addObject(obj.Name, objectType, obj.X, obj.Y, obj.Width, obj.Height, obj.Gid, tmxProperties(obj.Properties))
+			}
+		case "group":
+			err := addTMXLayers(b, layer.Layers)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func tmxLayerData(data tmxData, tiles int) ([]uint32, error) {
+	if data.Encoding == "" {
+		gids := make([]uint32, len(data.Tiles))
+		for i, tile := range data.Tiles {
+			gids[i] = tile.Gid
+		}
+		return gids, nil
+	}
+	return decodeData(data.Encoding, data.Compression, data.Content, tiles)
+}
@@ -0,0 +1,154 @@
+package tilemap
+
+import (
+	"sync"
+	"time"
+)
+
+const DefaultChunkSize = 32
+
+type ChunkCoord struct {
+	X, Y int
+}
+
+// Generator returns every layer of the tile at a tile coordinate, with EmptyTile on layers
+// that have nothing. It is only called for tiles inside the world and must be deterministic
+// so evicted chunks regenerate identically.
+type Generator func(x, y int) [LayerCount]Tile
+
+type Chunk struct {
+	Coord      ChunkCoord
+	layers     [LayerCount][]Tile
+	lastAccess time.Time
+}
+
+// ChunkedTilemap generates fixed size chunks on first access and drops them once idle,
+// so only the chunks around players need to be held in memory. It can't be edited.
+type ChunkedTilemap struct {
+	Grid
+	ChunkSize int // In Tiles
+	generate  Generator
+
+	mu     sync.Mutex
+	chunks map[ChunkCoord]*Chunk
+}
+
+func NewChunked(width int, height int, tileSize int, chunkSize int, generate Generator) *ChunkedTilemap {
+	return &ChunkedTilemap{
+		Grid:      Grid{TileSize: tileSize, width: width, height: height},
+		ChunkSize: chunkSize,
+		generate:  generate,
+		chunks:    make(map[ChunkCoord]*Chunk),
+	}
+}
+
+func (tilemap *ChunkedTilemap) ToChunk(x int, y int) ChunkCoord {
+	return ChunkCoord{floorDiv(x, tilemap.ChunkSize), floorDiv(y, tilemap.ChunkSize)}
+}
+
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
+
+func (tilemap *ChunkedTilemap) Get(x int, y int) (Tile, bool) {
+	return tilemap.GetLayer(GroundLayer, x, y)
+}
+
+// GetLayer returns the tile on a layer. Tiles outside the world are never generated.
+func (tilemap *ChunkedTilemap) GetLayer(layer Layer, x int, y int) (Tile, bool) {
+	if layer >= LayerCount || !tilemap.inBounds(x, y) {
+		return Tile{}, false
+	}
+	coord := tilemap.ToChunk(x, y)
+	localX := x - coord.X*tilemap.ChunkSize
+	localY := y - coord.Y*tilemap.ChunkSize
+
+	tilemap.mu.Lock()
+	chunk := tilemap.chunk(coord)
+	tile := chunk.layers[layer][localX*tilemap.ChunkSize+localY]
+	tilemap.mu.Unlock()
+
+	return tile, true
+}
+
+// HasLayer reports true for every layer, as the generator fills them all.
+func (tilemap *ChunkedTilemap) HasLayer(layer Layer) bool {
+	return layer < LayerCount
+}
+
+// Collides reports whether the collision layer blocks a cell.
+func (tilemap *ChunkedTilemap) Collides(x int, y int) bool {
+	tile, ok := tilemap.GetLayer(CollisionLayer, x, y)
+	return ok && tile.Type != EmptyTile
+}
+
+// Load makes sure every chunk of the world within radius chunks of the center is generated.
+func (tilemap *ChunkedTilemap) Load(center ChunkCoord, radius int) {
+	tilemap.mu.Lock()
+	defer tilemap.mu.Unlock()
+
+	last := tilemap.ToChunk(tilemap.width-1, tilemap.height-1)
+	for x := maxInt(center.X-radius, 0); x <= minInt(center.X+radius, last.X); x++ {
+		for y := maxInt(center.Y-radius, 0); y <= minInt(center.Y+radius, last.Y); y++ {
+			tilemap.chunk(ChunkCoord{x, y})
+		}
+	}
+}
+
+// EvictIdle drops every chunk that hasn't been accessed for the idle duration,
+// returning how many were dropped.
+func (tilemap *ChunkedTilemap) EvictIdle(idle time.Duration) int {
+	tilemap.mu.Lock()
+	defer tilemap.mu.Unlock()
+
+	evicted := 0
+	for coord, chunk := range tilemap.chunks {
+		if time.Since(chunk.lastAccess) > idle {
+			delete(tilemap.chunks, coord)
+			evicted++
+		}
+	}
+	return evicted
+}
+
+func (tilemap *ChunkedTilemap) Loaded() int {
+	tilemap.mu.Lock()
+	defer tilemap.mu.Unlock()
+	return len(tilemap.chunks)
+}
+
+// chunk must be called with the lock held.
+func (tilemap *ChunkedTilemap) chunk(coord ChunkCoord) *Chunk {
+	chunk, ok := tilemap.chunks[coord]
+	if !ok {
+		chunk = tilemap.generateChunk(coord)
+		tilemap.chunks[coord] = chunk
+	}
+	chunk.lastAccess = time.Now()
+	return chunk
+}
+
+func (tilemap *ChunkedTilemap) generateChunk(coord ChunkCoord) *Chunk {
+	size := tilemap.ChunkSize
+	chunk := &Chunk{Coord: coord}
+	for layer := range chunk.layers {
+		chunk.layers[layer] = make([]Tile, size*size)
+	}
+	for x := 0; x < size; x++ {
+		for y := 0; y < size; y++ {
+			worldX, worldY := coord.X*size+x, coord.Y*size+y
+			if !tilemap.inBounds(worldX, worldY) {
+				continue
+			}
+			tiles := tilemap.generate(worldX, worldY)
+			for layer := range chunk.layers {
+				chunk.layers[layer][x*size+y] = tiles[layer]
+			}
+		}
+	}
+	return chunk
+}
@@ -0,0 +1,160 @@
+package tilemap
+
+import "math"
+
+type Point struct {
+	X, Y int
+}
+
+// Manhattan is the number of steps between two points moving along the axes.
+func (p Point) Manhattan(q Point) int {
+	return absInt(p.X-q.X) + absInt(p.Y-q.Y)
+}
+
+// Chebyshev is the number of steps between two points moving diagonally as well.
+func (p Point) Chebyshev(q Point) int {
+	return maxInt(absInt(p.X-q.X), absInt(p.Y-q.Y))
+}
+
+var (
+	Directions4 = []Point{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+	Directions8 = []Point{{1, 0}, {-1, 0}, {0, 1}, {0, -1}, {1, 1}, {1, -1}, {-1, 1}, {-1, -1}}
+)
+
+// Rect is a world space rectangle, inclusive of the min edge and exclusive of the max edge.
+type Rect struct {
+	MinX, MinY float64
+	MaxX, MaxY float64
+}
+
+func (rect Rect) Contains(x float64, y float64) bool {
+	return x >= rect.MinX && x < rect.MaxX && y >= rect.MinY && y < rect.MaxY
+}
+
+func (rect Rect) Width() float64 {
+	return rect.MaxX - rect.MinX
+}
+
+// Grid is the size of a map and of its tiles, and converts between tile and world
+// coordinates. Tilemap and ChunkedTilemap both embed one.
+type Grid struct {
+	TileSize int // In Pixels
+	// The size never changes, so it can be read without any lock
+	width, height int // In Tiles
+}
+
+func (grid Grid) Width() int {
+	return grid.width
+}
+
+func (grid Grid) Height() int {
+	return grid.height
+}
+
+func (grid Grid) inBounds(x int, y int) bool {
+	return x >= 0 && x < grid.width && y >= 0 && y < grid.height
+}
+
+// WorldToTile returns the tile containing a world position. Tiles are centered on
+// x*TileSize, so each tile covers half a tile either side of its center.
+func (grid Grid) WorldToTile(x float64, y float64) (int, int) {
+	tileSize := float64(grid.TileSize)
+	return int(math.Floor(x/tileSize + 0.5)), int(math.Floor(y/tileSize + 0.5))
+}
+
+func (grid Grid) TileToWorld(x int, y int) (float64, float64) {
+	return float64(x * grid.TileSize), float64(y * grid.TileSize)
+}
+
+func (grid Grid) TileBounds(x int, y int) Rect {
+	centerX, centerY := grid.TileToWorld(x, y)
+	half := float64(grid.TileSize) / 2
+	return Rect{centerX - half, centerY - half, centerX + half, centerY + half}
+}
+
+func (grid Grid) WorldBounds() Rect {
+	min := grid.TileBounds(0, 0)
+	max := grid.TileBounds(grid.Width()-1, grid.Height()-1)
+	return Rect{min.MinX, min.MinY, max.MaxX, max.MaxY}
+}
+
+// CenterTile is the tile in the middle of the map.
+func (grid Grid) CenterTile() Point {
+	return Point{grid.Width() / 2, grid.Height() / 2}
+}
+
+// EachInRect calls f for every tile of the map overlapping a world space rectangle.
+func (grid Grid) EachInRect(rect Rect, f func(x, y int)) {
+	if rect.MaxX <= rect.MinX || rect.MaxY <= rect.MinY {
+		return
+	}
+
+	// The max edge is exclusive, so a tile only overlaps if its min edge is below it
+	tileSize := float64(grid.TileSize)
+	minX, minY := grid.WorldToTile(rect.MinX, rect.MinY)
+	maxX := int(math.Ceil(rect.MaxX/tileSize+0.5)) - 1
+	maxY := int(math.Ceil(rect.MaxY/tileSize+0.5)) - 1
+	minX, minY = maxInt(minX, 0), maxInt(minY, 0)
+	maxX, maxY = minInt(maxX, grid.Width()-1), minInt(maxY, grid.Height()-1)
+
+	for x := minX; x <= maxX; x++ {
+		for y := minY; y <= maxY; y++ {
+			f(x, y)
+		}
+	}
+}
+
+// EachInCircle calls f for every tile of the map whose center is within radius of a world position.
+func (grid Grid) EachInCircle(centerX float64, centerY float64, radius float64, f func(x, y int)) {
+	radiusSquared := radius * radius
+	rect := Rect{centerX - radius, centerY - radius, centerX + radius, centerY + radius}
+	grid.EachInRect(rect, func(x, y int) {
+		worldX, worldY := grid.TileToWorld(x, y)
+		dx, dy := worldX-centerX, worldY-centerY
+		if dx*dx+dy*dy <= radiusSquared {
+			f(x, y)
+		}
+	})
+}
+
+// Neighbors4 returns the in bounds tiles sharing an edge with p.
+func (grid Grid) Neighbors4(p Point) []Point {
+	return grid.neighbors(p, Directions4)
+}
+
+// Neighbors8 returns the in bounds tiles sharing an edge or corner with p.
+func (grid Grid) Neighbors8(p Point) []Point {
+	return grid.neighbors(p, Directions8)
+}
+
+func (grid Grid) neighbors(p Point, directions []Point) []Point {
+	neighbors := make([]Point, 0, len(directions))
+	for _, dir := range directions {
+		n := Point{p.X + dir.X, p.Y + dir.Y}
+		if grid.inBounds(n.X, n.Y) {
+			neighbors = append(neighbors, n)
+		}
+	}
+	return neighbors
+}
+
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,178 @@
+package tilemap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+var ErrInvalidChange = errors.New("invalid tilemap change")
+
+type Cell struct {
+	Layer Layer
+	X, Y  int
+	Tile  Tile
+}
+
+// Change lists every cell modified by a single edit, holding the new tiles.
+type Change struct {
+	Cells []Cell
+}
+
+// Bounds returns the inclusive tile rectangle covering every changed cell.
+func (change Change) Bounds() (minX, minY, maxX, maxY int) {
+	for i, cell := range change.Cells {
+		if i == 0 || cell.X < minX {
+			minX = cell.X
+		}
+		if i == 0 || cell.Y < minY {
+			minY = cell.Y
+		}
+		if i == 0 || cell.X > maxX {
+			maxX = cell.X
+		}
+		if i == 0 || cell.Y > maxY {
+			maxY = cell.Y
+		}
+	}
+	return minX, minY, maxX, maxY
+}
+
+type ChangeListener func(change Change)
+
+// OnChange registers a listener called after every edit that modifies at least one cell.
+// Listeners run on the goroutine that made the edit.
+func (tilemap *Tilemap) OnChange(listener ChangeListener) {
+	tilemap.mu.Lock()
+	tilemap.listeners = append(tilemap.listeners, listener)
+	tilemap.mu.Unlock()
+}
+
+func (tilemap *Tilemap) notify(change Change) {
+	if len(change.Cells) == 0 {
+		return
+	}
+	tilemap.mu.RLock()
+	listeners := tilemap.listeners
+	tilemap.mu.RUnlock()
+
+	for _, listener := range listeners {
+		listener(change)
+	}
+}
+
+// Set replaces a ground tile, returning false if the position is outside the map.
+func (tilemap *Tilemap) Set(x int, y int, tile Tile) bool {
+	return tilemap.Apply([]Cell{{Layer: GroundLayer, X: x, Y: y, Tile: tile}})
+}
+
+func (tilemap *Tilemap) SetOnLayer(layer Layer, x int, y int, tile Tile) bool {
+	return tilemap.Apply([]Cell{{Layer: layer, X: x, Y: y, Tile: tile}})
+}
+
+// Fill sets every tile of a layer in the inclusive rectangle from (x0, y0) to (x1, y1).
+// Nothing is changed unless the whole rectangle is inside the map.
+func (tilemap *Tilemap) Fill(layer Layer, x0, y0, x1, y1 int, tile Tile) bool {
+	if x0 > x1 {
+		x0, x1 = x1, x0
+	}
+	if y0 > y1 {
+		y0, y1 = y1, y0
+	}
+	if !tilemap.inBounds(x0, y0) || !tilemap.inBounds(x1, y1) {
+		return false
+	}
+
+	cells := make([]Cell, 0, (x1-x0+1)*(y1-y0+1))
+	for x := x0; x <= x1; x++ {
+		for y := y0; y <= y1; y++ {
+			cells = append(cells, Cell{Layer: layer, X: x, Y: y, Tile: tile})
+		}
+	}
+	return tilemap.Apply(cells)
+}
+
+// Apply sets a batch of cells as one edit. Nothing is changed if any cell is outside the
+// map or on an unknown layer, and the change event only lists cells that actually changed.
+func (tilemap *Tilemap) Apply(cells []Cell) bool {
+	for _, cell := range cells {
+		if cell.Layer >= LayerCount || !tilemap.inBounds(cell.X, cell.Y) {
+			return false
+		}
+		if cell.Layer == GroundLayer && cell.Tile.Type == EmptyTile {
+			return false
+		}
+	}
+
+	change := Change{}
+	tilemap.mu.Lock()
+	for _, cell := range cells {
+		current, _ := tilemap.getLayer(cell.Layer, cell.X, cell.Y)
+		if current == cell.Tile {
+			continue
+		}
+		if tilemap.layers[cell.Layer] == nil {
+			tilemap.layers[cell.Layer] = tilemap.NewLayer()
+		}
+		tilemap.layers[cell.Layer][cell.X][cell.Y] = cell.Tile
+		change.Cells = append(change.Cells, cell)
+	}
+	tilemap.mu.Unlock()
+
+	tilemap.notify(change)
+	return true
+}
+
+// MarshalBinary encodes a change as a uvarint cell count followed by
+// (uint8 layer, uvarint x, uvarint y, uint8 TileType) for every cell.
+func (change Change) MarshalBinary() ([]byte, error) {
+	buf := bytes.Buffer{}
+	varint := make([]byte, binary.MaxVarintLen64)
+
+	n := binary.PutUvarint(varint, uint64(len(change.Cells)))
+	buf.Write(varint[:n])
+	for _, cell := range change.Cells {
+		buf.WriteByte(byte(cell.Layer))
+		n = binary.PutUvarint(varint, uint64(cell.X))
+		buf.Write(varint[:n])
+		n = binary.PutUvarint(varint, uint64(cell.Y))
+		buf.Write(varint[:n])
+		buf.WriteByte(byte(cell.Tile.Type))
+	}
+	return buf.Bytes(), nil
+}
+
+func (change *Change) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+	count, err := binary.ReadUvarint(r)
+	if err != nil || count > uint64(len(data)) {
+		return ErrInvalidChange
+	}
+
+	cells := make([]Cell, count)
+	for i := range cells {
+		layer, err := r.ReadByte()
+		if err != nil || Layer(layer) >= LayerCount {
+			return ErrInvalidChange
+		}
+		x, err := binary.ReadUvarint(r)
+		if err != nil || x > maxFileMapSize {
+			return ErrInvalidChange
+		}
+		y, err := binary.ReadUvarint(r)
+		if err != nil || y > maxFileMapSize {
+			return ErrInvalidChange
+		}
+		tileType, err := r.ReadByte()
+		if err != nil {
+			return ErrInvalidChange
+		}
+		cells[i] = Cell{Layer: Layer(layer), X: int(x), Y: int(y), Tile: Tile{Type: TileType(tileType)}}
+	}
+	if r.Len() != 0 {
+		return ErrInvalidChange
+	}
+
+	change.Cells = cells
+	return nil
+}
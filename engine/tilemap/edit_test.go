@@ -0,0 +1,60 @@
+package tilemap
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestChangeMarshal(t *testing.T) {
+	tests := []struct {
+		name   string
+		change Change
+	}{
+		{"empty", Change{Cells: []Cell{}}},
+		{"one cell", Change{Cells: []Cell{{Layer: GroundLayer, X: 3, Y: 4, Tile: Tile{Type: 2}}}}},
+		{"every layer", Change{Cells: []Cell{
+			{Layer: GroundLayer, X: 0, Y: 0, Tile: Tile{Type: 1}},
+			{Layer: DecorationLayer, X: 300, Y: 1, Tile: Tile{Type: 255}},
+			{Layer: OverlayLayer, X: 1, Y: maxFileMapSize, Tile: Tile{Type: 0}},
+			{Layer: CollisionLayer, X: maxFileMapSize, Y: 4464, Tile: Tile{Type: EmptyTile}},
+		}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := test.change.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := Change{}
+			err = got.UnmarshalBinary(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got.Cells, test.change.Cells) {
+				t.Fatalf("got %v, want %v", got.Cells, test.change.Cells)
+			}
+
+			// Every shorter prefix is missing part of a cell, or the count
+			for n := 0; n < len(data); n++ {
+				if (&Change{}).UnmarshalBinary(data[:n]) == nil {
+					t.Fatalf("change truncated to %d of %d bytes decoded without an error", n, len(data))
+				}
+			}
+			if (&Change{}).UnmarshalBinary(append(data, 0)) == nil {
+				t.Fatal("change with trailing data decoded without an error")
+			}
+		})
+	}
+}
+
+func TestChangeUnmarshalRejectsUnknownLayers(t *testing.T) {
+	data, err := Change{Cells: []Cell{{Layer: GroundLayer, X: 1, Y: 1}}}.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The layer byte follows the uvarint cell count
+	data[1] = byte(LayerCount)
+	if (&Change{}).UnmarshalBinary(data) != ErrInvalidChange {
+		t.Fatal("change on an unknown layer decoded without an error")
+	}
+}
@@ -0,0 +1,26 @@
+package tilemap
+
+// Map reads tiles from a map, whether it is held in memory whole like Tilemap or
+// generated a chunk at a time like ChunkedTilemap.
+type Map interface {
+	Width() int
+	Height() int
+	WorldToTile(x float64, y float64) (int, int)
+	TileToWorld(x int, y int) (float64, float64)
+	TileBounds(x int, y int) Rect
+	WorldBounds() Rect
+	CenterTile() Point
+
+	Get(x int, y int) (Tile, bool)
+	GetLayer(layer Layer, x int, y int) (Tile, bool)
+	HasLayer(layer Layer) bool
+	Collides(x int, y int) bool
+}
+
+// Editable is a Map that can change after it is created, telling listeners when it does.
+// Caches built from a map only need to be dropped for maps that are Editable.
+type Editable interface {
+	Map
+	OnChange(listener ChangeListener)
+	Apply(cells []Cell) bool
+}
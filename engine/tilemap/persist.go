@@ -0,0 +1,267 @@
+package tilemap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Files are little endian: a fileHeader, then for each layer in the layer mask every chunk
+// in x major order, stored as a uint32 length followed by (uvarint run, uint8 TileType) pairs.
+// Tiles inside a chunk are also stored x major, the same as Tilemap.
+const (
+	fileMagic      = "GMAP"
+	fileVersion    = 1
+	fileChunkSize  = 64
+	maxFileMapSize = 1 << 16
+	// Limits checked before anything is allocated, so a corrupt or hostile header can't
+	// make Load allocate gigabytes
+	maxFileTiles     = 1 << 26
+	maxFileChunkSize = 1024
+	maxFileTileSize  = 1024
+)
+
+var ErrInvalidFile = errors.New("invalid tilemap file")
+
+type Header struct {
+	Width     int
+	Height    int
+	TileSize  int
+	Seed      int64
+	ChunkSize int
+}
+
+type fileHeader struct {
+	Magic     [4]byte
+	Version   uint16
+	Width     uint32
+	Height    uint32
+	TileSize  uint32
+	Seed      int64
+	ChunkSize uint16
+	LayerMask uint8
+}
+
+func Save(w io.Writer, tmap *Tilemap, seed int64) error {
+	header := fileHeader{
+		Version:   fileVersion,
+		Width:     uint32(tmap.Width()),
+		Height:    uint32(tmap.Height()),
+		TileSize:  uint32(tmap.TileSize),
+		Seed:      seed,
+		ChunkSize: fileChunkSize,
+	}
+	copy(header.Magic[:], fileMagic)
+
+	tmap.mu.RLock()
+	defer tmap.mu.RUnlock()
+	for layer := Layer(0); layer < LayerCount; layer++ {
+		if tmap.layers[layer] != nil {
+			header.LayerMask |= 1 << layer
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	err := binary.Write(bw, binary.LittleEndian, header)
+	if err != nil {
+		return err
+	}
+
+	chunk := bytes.Buffer{}
+	for layer := Layer(0); layer < LayerCount; layer++ {
+		if header.LayerMask&(1<<layer) == 0 {
+			continue
+		}
+		tiles := tmap.layers[layer]
+		for cx := 0; cx < tmap.Width(); cx += fileChunkSize {
+			for cy := 0; cy < tmap.Height(); cy += fileChunkSize {
+				chunk.Reset()
+				encodeChunk(&chunk, tiles, cx, cy)
+				err = binary.Write(bw, binary.LittleEndian, uint32(chunk.Len()))
+				if err != nil {
+					return err
+				}
+				_, err = bw.Write(chunk.Bytes())
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return bw.Flush()
+}
+
+func encodeChunk(buf *bytes.Buffer, tiles [][]Tile, cx, cy int) {
+	varint := make([]byte, binary.MaxVarintLen64)
+	run := uint64(0)
+	current := TileType(0)
+	flush := func() {
+		if run == 0 {
+			return
+		}
+		n := binary.PutUvarint(varint, run)
+		buf.Write(varint[:n])
+		buf.WriteByte(byte(current))
+	}
+
+	for x := cx; x < cx+fileChunkSize && x < len(tiles); x++ {
+		for y := cy; y < cy+fileChunkSize && y < len(tiles[x]); y++ {
+			tileType := tiles[x][y].Type
+			if run > 0 && tileType == current {
+				run++
+				continue
+			}
+			flush()
+			current = tileType
+			run = 1
+		}
+	}
+	flush()
+}
+
+func Load(r io.Reader) (*Tilemap, Header, error) {
+	br := bufio.NewReader(r)
+
+	header := fileHeader{}
+	err := binary.Read(br, binary.LittleEndian, &header)
+	if err != nil {
+		return nil, Header{}, err
+	}
+	if string(header.Magic[:]) != fileMagic {
+		return nil, Header{}, ErrInvalidFile
+	}
+	if header.Version != fileVersion {
+		return nil, Header{}, fmt.Errorf("unsupported tilemap file version %d", header.Version)
+	}
+	if header.Width == 0 || header.Height == 0 || header.Width > maxFileMapSize || header.Height > maxFileMapSize ||
+		header.ChunkSize == 0 || header.ChunkSize > maxFileChunkSize ||
+		header.TileSize == 0 || header.TileSize > maxFileTileSize ||
+		header.LayerMask&(1<<GroundLayer) == 0 || header.LayerMask >= 1<<LayerCount {
+		return nil, Header{}, ErrInvalidFile
+	}
+	layers := 0
+	for layer := Layer(0); layer < LayerCount; layer++ {
+		if header.LayerMask&(1<<layer) != 0 {
+			layers++
+		}
+	}
+	if uint64(header.Width)*uint64(header.Height)*uint64(layers) > maxFileTiles {
+		return nil, Header{}, ErrInvalidFile
+	}
+
+	width, height, chunkSize := int(header.Width), int(header.Height), int(header.ChunkSize)
+	tmap := &Tilemap{Grid: Grid{TileSize: int(header.TileSize), width: width, height: height}}
+	for layer := Layer(0); layer < LayerCount; layer++ {
+		if header.LayerMask&(1<<layer) == 0 {
+			continue
+		}
+		tiles := make([][]Tile, width)
+		for x := range tiles {
+			tiles[x] = make([]Tile, height)
+		}
+		for cx := 0; cx < width; cx += chunkSize {
+			for cy := 0; cy < height; cy += chunkSize {
+				err = decodeChunk(br, tiles, cx, cy, chunkSize)
+				if err != nil {
+					return nil, Header{}, err
+				}
+			}
+		}
+		tmap.layers[layer] = tiles
+	}
+
+	return tmap, Header{
+		Width:     width,
+		Height:    height,
+		TileSize:  int(header.TileSize),
+		Seed:      header.Seed,
+		ChunkSize: chunkSize,
+	}, nil
+}
+
+func decodeChunk(br *bufio.Reader, tiles [][]Tile, cx, cy, chunkSize int) error {
+	length := uint32(0)
+	err := binary.Read(br, binary.LittleEndian, &length)
+	if err != nil {
+		return err
+	}
+	// Every run takes at least one tile and its uvarint length is never longer than the
+	// run, so a chunk takes at most two bytes a tile
+	width, height := minInt(chunkSize, len(tiles)-cx), minInt(chunkSize, len(tiles[cx])-cy)
+	if uint64(length) > 2*uint64(width)*uint64(height) {
+		return ErrInvalidFile
+	}
+	data := make([]byte, length)
+	_, err = io.ReadFull(br, data)
+	if err != nil {
+		return err
+	}
+
+	chunk := bytes.NewReader(data)
+	run := uint64(0)
+	current := TileType(0)
+	for x := cx; x < cx+chunkSize && x < len(tiles); x++ {
+		for y := cy; y < cy+chunkSize && y < len(tiles[x]); y++ {
+			if run == 0 {
+				run, err = binary.ReadUvarint(chunk)
+				if err != nil || run == 0 {
+					return ErrInvalidFile
+				}
+				tileType, err := chunk.ReadByte()
+				if err != nil {
+					return ErrInvalidFile
+				}
+				current = TileType(tileType)
+			}
+			tiles[x][y] = Tile{Type: current}
+			run--
+		}
+	}
+	if run != 0 || chunk.Len() != 0 {
+		return ErrInvalidFile
+	}
+	return nil
+}
+
+// SaveFile writes the map to a temporary file next to path and renames it over path once
+// it is safely on disk, so a crash part way through never loses the previous save.
+func SaveFile(path string, tmap *Tilemap, seed int64) error {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := file.Name()
+
+	err = Save(file, tmap, seed)
+	if err == nil {
+		err = file.Sync()
+	}
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func LoadFile(path string) (*Tilemap, Header, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, Header{}, err
+	}
+	defer file.Close()
+
+	return Load(file)
+}
@@ -0,0 +1,195 @@
+package tilemap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"path/filepath"
+	"testing"
+)
+
+// testMap fills every layer with a pattern that has both long runs and changes every tile,
+// so chunks of every shape get encoded.
+func testMap(width, height int, layers ...Layer) *Tilemap {
+	pattern := func(layer Layer) [][]Tile {
+		tiles := make([][]Tile, width)
+		for x := range tiles {
+			tiles[x] = make([]Tile, height)
+			for y := range tiles[x] {
+				tileType := TileType(0)
+				if x > width/2 {
+					tileType = TileType((x*7 + y*13 + int(layer)) % 5)
+				}
+				tiles[x][y] = Tile{Type: tileType}
+			}
+		}
+		return tiles
+	}
+
+	tmap := New(pattern(GroundLayer), 16)
+	for _, layer := range layers {
+		tmap.SetLayer(layer, pattern(layer))
+	}
+	return tmap
+}
+
+func assertSameMap(t *testing.T, got *Tilemap, want *Tilemap) {
+	t.Helper()
+	if got.Width() != want.Width() || got.Height() != want.Height() || got.TileSize != want.TileSize {
+		t.Fatalf("got a %dx%d map of %d pixel tiles, want %dx%d of %d", got.Width(), got.Height(), got.TileSize, want.Width(), want.Height(), want.TileSize)
+	}
+	for layer := Layer(0); layer < LayerCount; layer++ {
+		if got.HasLayer(layer) != want.HasLayer(layer) {
+			t.Fatalf("layer %s: has layer %v, want %v", layer, got.HasLayer(layer), want.HasLayer(layer))
+		}
+		if !want.HasLayer(layer) {
+			continue
+		}
+		for x := 0; x < want.Width(); x++ {
+			for y := 0; y < want.Height(); y++ {
+				gotTile, _ := got.GetLayer(layer, x, y)
+				wantTile, _ := want.GetLayer(layer, x, y)
+				if gotTile != wantTile {
+					t.Fatalf("layer %s tile (%d, %d) is %v, want %v", layer, x, y, gotTile, wantTile)
+				}
+			}
+		}
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	tests := []struct {
+		name string
+		tmap *Tilemap
+		seed int64
+	}{
+		{"single tile", testMap(1, 1), 0},
+		{"ground only", testMap(fileChunkSize, fileChunkSize), 1},
+		{"partial chunks", testMap(fileChunkSize*2+3, fileChunkSize+1, DecorationLayer), -42},
+		{"every layer", testMap(70, 130, DecorationLayer, OverlayLayer, CollisionLayer), 1 << 40},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := bytes.Buffer{}
+			err := Save(&buf, test.tmap, test.seed)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			loaded, header, err := Load(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if header.Seed != test.seed || header.ChunkSize != fileChunkSize {
+				t.Fatalf("got seed %d and chunk size %d, want %d and %d", header.Seed, header.ChunkSize, test.seed, fileChunkSize)
+			}
+			assertSameMap(t, loaded, test.tmap)
+		})
+	}
+}
+
+func TestSaveFileLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "world.gmap")
+	tmap := testMap(100, 90, CollisionLayer)
+
+	// Saving twice replaces the first save
+	for seed := int64(1); seed <= 2; seed++ {
+		err := SaveFile(path, tmap, seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	loaded, header, err := LoadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Seed != 2 {
+		t.Fatalf("got seed %d, want 2", header.Seed)
+	}
+	assertSameMap(t, loaded, tmap)
+
+	matches, err := filepath.Glob(filepath.Join(filepath.Dir(path), "*.tmp"))
+	if err != nil || len(matches) != 0 {
+		t.Fatalf("temporary files left behind: %v", matches)
+	}
+}
+
+// Offsets of the fileHeader fields, which binary.Write packs without padding
+const (
+	headerVersion   = 4
+	headerWidth     = 6
+	headerTileSize  = 14
+	headerChunkSize = 26
+	headerLayerMask = 28
+	headerSize      = 29
+)
+
+func TestLoadRejectsCorruptFiles(t *testing.T) {
+	if binary.Size(fileHeader{}) != headerSize {
+		t.Fatalf("file header is %d bytes, the offsets below assume %d", binary.Size(fileHeader{}), headerSize)
+	}
+	valid := bytes.Buffer{}
+	err := Save(&valid, testMap(fileChunkSize+5, 20, DecorationLayer), 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		corrupt func(data []byte) []byte
+	}{
+		{"empty", func(data []byte) []byte { return nil }},
+		{"truncated header", func(data []byte) []byte { return data[:headerSize-1] }},
+		{"header only", func(data []byte) []byte { return data[:headerSize] }},
+		{"truncated chunk", func(data []byte) []byte { return data[:len(data)-1] }},
+		{"bad magic", func(data []byte) []byte {
+			data[0] = 'X'
+			return data
+		}},
+		{"unknown version", func(data []byte) []byte {
+			binary.LittleEndian.PutUint16(data[headerVersion:], fileVersion+1)
+			return data
+		}},
+		{"zero width", func(data []byte) []byte {
+			binary.LittleEndian.PutUint32(data[headerWidth:], 0)
+			return data
+		}},
+		{"huge width", func(data []byte) []byte {
+			binary.LittleEndian.PutUint32(data[headerWidth:], maxFileMapSize+1)
+			return data
+		}},
+		{"zero tile size", func(data []byte) []byte {
+			binary.LittleEndian.PutUint32(data[headerTileSize:], 0)
+			return data
+		}},
+		{"zero chunk size", func(data []byte) []byte {
+			binary.LittleEndian.PutUint16(data[headerChunkSize:], 0)
+			return data
+		}},
+		{"no ground layer", func(data []byte) []byte {
+			data[headerLayerMask] &^= 1 << GroundLayer
+			return data
+		}},
+		{"unknown layer", func(data []byte) []byte {
+			data[headerLayerMask] |= 1 << LayerCount
+			return data
+		}},
+		{"oversized chunk length", func(data []byte) []byte {
+			binary.LittleEndian.PutUint32(data[headerSize:], 1<<31)
+			return data
+		}},
+		{"zero length run", func(data []byte) []byte {
+			data[headerSize+4] = 0
+			return data
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := test.corrupt(append([]byte(nil), valid.Bytes()...))
+			_, _, err := Load(bytes.NewReader(data))
+			if err == nil {
+				t.Fatal("corrupt file loaded without an error")
+			}
+		})
+	}
+}
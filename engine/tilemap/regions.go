@@ -0,0 +1,144 @@
+package tilemap
+
+type Predicate func(tile Tile) bool
+
+// FloodFill returns every ground tile matching the predicate that is 4-connected to start.
+func (tilemap *Tilemap) FloodFill(start Point, match Predicate) []Point {
+	tile, ok := tilemap.Get(start.X, start.Y)
+	if !ok || !match(tile) {
+		return nil
+	}
+
+	visited := make(map[Point]bool)
+	visited[start] = true
+	filled := []Point{start}
+	for i := 0; i < len(filled); i++ {
+		for _, n := range tilemap.Neighbors4(filled[i]) {
+			if visited[n] {
+				continue
+			}
+			visited[n] = true
+			tile, _ := tilemap.Get(n.X, n.Y)
+			if match(tile) {
+				filled = append(filled, n)
+			}
+		}
+	}
+	return filled
+}
+
+type Region struct {
+	Id   int
+	Size int
+	// Inclusive tile bounds
+	Min, Max Point
+	// First tile found in the region, useful for flood filling it again
+	Start Point
+	// Whether any tile of the region lies on the edge of the map
+	TouchesEdge bool
+}
+
+// Regions labels every ground tile with the id of the 4-connected region it belongs to.
+// Ids start at 1, tiles that didn't match the predicate have the label 0.
+type Regions struct {
+	Regions []Region
+	labels  []int32
+	width   int
+	height  int
+}
+
+func (regions *Regions) Label(x int, y int) int {
+	if x < 0 || x >= regions.width || y < 0 || y >= regions.height {
+		return 0
+	}
+	return int(regions.labels[x*regions.height+y])
+}
+
+func (regions *Regions) Get(id int) (Region, bool) {
+	if id <= 0 || id > len(regions.Regions) {
+		return Region{}, false
+	}
+	return regions.Regions[id-1], true
+}
+
+func (regions *Regions) At(x int, y int) (Region, bool) {
+	return regions.Get(regions.Label(x, y))
+}
+
+// Points returns every tile of a region, found from its labels without flood filling it again.
+func (regions *Regions) Points(id int) []Point {
+	region, ok := regions.Get(id)
+	if !ok {
+		return nil
+	}
+	points := make([]Point, 0, region.Size)
+	for x := region.Min.X; x <= region.Max.X; x++ {
+		for y := region.Min.Y; y <= region.Max.Y; y++ {
+			if regions.Label(x, y) == id {
+				points = append(points, Point{x, y})
+			}
+		}
+	}
+	return points
+}
+
+func (regions *Regions) Largest() (Region, bool) {
+	largest := Region{}
+	for _, region := range regions.Regions {
+		if region.Size > largest.Size {
+			largest = region
+		}
+	}
+	return largest, largest.Id != 0
+}
+
+func (tilemap *Tilemap) LabelRegions(match Predicate) *Regions {
+	width, height := tilemap.Width(), tilemap.Height()
+	regions := &Regions{
+		labels: make([]int32, width*height),
+		width:  width,
+		height: height,
+	}
+
+	matches := func(p Point) bool {
+		tile, _ := tilemap.Get(p.X, p.Y)
+		return match(tile)
+	}
+
+	queue := []Point{}
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			start := Point{x, y}
+			if regions.labels[x*height+y] != 0 || !matches(start) {
+				continue
+			}
+
+			id := int32(len(regions.Regions) + 1)
+			region := Region{Id: int(id), Min: start, Max: start, Start: start}
+			regions.labels[x*height+y] = id
+			queue = append(queue[:0], start)
+			for len(queue) > 0 {
+				p := queue[len(queue)-1]
+				queue = queue[:len(queue)-1]
+
+				region.Size++
+				region.Min = Point{minInt(region.Min.X, p.X), minInt(region.Min.Y, p.Y)}
+				region.Max = Point{maxInt(region.Max.X, p.X), maxInt(region.Max.Y, p.Y)}
+				if p.X == 0 || p.Y == 0 || p.X == width-1 || p.Y == height-1 {
+					region.TouchesEdge = true
+				}
+
+				for _, n := range tilemap.Neighbors4(p) {
+					i := n.X*height + n.Y
+					if regions.labels[i] != 0 || !matches(n) {
+						continue
+					}
+					regions.labels[i] = id
+					queue = append(queue, n)
+				}
+			}
+			regions.Regions = append(regions.Regions, region)
+		}
+	}
+	return regions
+}
@@ -0,0 +1,98 @@
+package tilemap
+
+import (
+	"fmt"
+	"sort"
+)
+
+type TileDefinition struct {
+	Type         TileType               `json:"type"`
+	Name         string                 `json:"name"`
+	Sprite       string                 `json:"sprite"`
+	Walkable     bool                   `json:"walkable"`
+	MovementCost float64                `json:"movementCost"`
+	Properties   map[string]interface{} `json:"properties"`
+}
+
+// Registry is the single source of truth for what each TileType means.
+type Registry struct {
+	definitions map[TileType]TileDefinition
+	names       map[string]TileType
+}
+
+func NewRegistry(definitions []TileDefinition) (*Registry, error) {
+	registry := &Registry{
+		definitions: make(map[TileType]TileDefinition),
+		names:       make(map[string]TileType),
+	}
+	for _, def := range definitions {
+		if def.Type == EmptyTile {
+			return nil, fmt.Errorf("tile %q uses the reserved empty tile type", def.Name)
+		}
+		if _, ok := registry.definitions[def.Type]; ok {
+			return nil, fmt.Errorf("duplicate tile type %d", def.Type)
+		}
+		if _, ok := registry.names[def.Name]; ok {
+			return nil, fmt.Errorf("duplicate tile name %q", def.Name)
+		}
+		if def.Walkable && def.MovementCost < 1 {
+			return nil, fmt.Errorf("tile %q must have a movement cost of at least 1", def.Name)
+		}
+		registry.definitions[def.Type] = def
+		registry.names[def.Name] = def.Type
+	}
+	return registry, nil
+}
+
+func (registry *Registry) Get(tileType TileType) (TileDefinition, bool) {
+	def, ok := registry.definitions[tileType]
+	return def, ok
+}
+
+func (registry *Registry) ByName(name string) (TileType, bool) {
+	tileType, ok := registry.names[name]
+	return tileType, ok
+}
+
+// BySprite finds the lowest TileType drawn with a sprite.
+func (registry *Registry) BySprite(sprite string) (TileType, bool) {
+	for _, tileType := range registry.Types() {
+		if registry.definitions[tileType].Sprite == sprite {
+			return tileType, true
+		}
+	}
+	return 0, false
+}
+
+// Types returns every registered TileType in ascending order.
+func (registry *Registry) Types() []TileType {
+	types := make([]TileType, 0, len(registry.definitions))
+	for tileType := range registry.definitions {
+		types = append(types, tileType)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+func (registry *Registry) Walkable(tileType TileType) bool {
+	def, ok := registry.definitions[tileType]
+	return ok && def.Walkable
+}
+
+// Cost returns the movement cost of a tile and whether it can be walked on at all.
+func (registry *Registry) Cost(tile Tile) (float64, bool) {
+	def, ok := registry.definitions[tile.Type]
+	if !ok || !def.Walkable {
+		return 0, false
+	}
+	return def.MovementCost, true
+}
+
+func (registry *Registry) Property(tileType TileType, key string) (interface{}, bool) {
+	def, ok := registry.definitions[tileType]
+	if !ok {
+		return nil, false
+	}
+	val, ok := def.Properties[key]
+	return val, ok
+}
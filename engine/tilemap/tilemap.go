@@ -1,32 +1,160 @@
 package tilemap
 
+import (
+	"math"
+	"sync"
+)
+
 type TileType uint8
 
+// EmptyTile marks cells of a non ground layer that have nothing in them.
+const EmptyTile TileType = math.MaxUint8
+
 type Tile struct {
 	Type TileType
 }
 
+type Layer uint8
+
+// Layers are drawn in order, entities are drawn between DecorationLayer and OverlayLayer.
+// Any tile on the CollisionLayer blocks movement and is never drawn.
+const (
+	GroundLayer Layer = iota
+	DecorationLayer
+	OverlayLayer
+	CollisionLayer
+	LayerCount
+)
+
+var layerNames = [LayerCount]string{"ground", "decoration", "overlay", "collision"}
+
+func (layer Layer) String() string {
+	if layer >= LayerCount {
+		return "unknown"
+	}
+	return layerNames[layer]
+}
+
+func LayerByName(name string) (Layer, bool) {
+	for i, layerName := range layerNames {
+		if layerName == name {
+			return Layer(i), true
+		}
+	}
+	return 0, false
+}
+
+// Tilemap is safe to read and edit from multiple goroutines. Reads see every edit either
+// completely or not at all, and listeners are called after the edit, outside of the lock.
 type Tilemap struct {
-	TileSize int // In Pixels
-	tiles    [][]Tile
+	Grid
+
+	mu        sync.RWMutex
+	layers    [LayerCount][][]Tile
+	listeners []ChangeListener
 }
 
 func New(tiles [][]Tile, tileSize int) *Tilemap {
-	return &Tilemap{tileSize, tiles}
+	tilemap := &Tilemap{Grid: Grid{TileSize: tileSize, width: len(tiles), height: len(tiles[0])}}
+	tilemap.layers[GroundLayer] = tiles
+	return tilemap
 }
 
-func (tilemap *Tilemap) Width() int {
-	return len(tilemap.tiles)
+func (tilemap *Tilemap) Get(x int, y int) (Tile, bool) {
+	tilemap.mu.RLock()
+	defer tilemap.mu.RUnlock()
+	return tilemap.getLayer(GroundLayer, x, y)
 }
 
-func (tilemap *Tilemap) Height() int {
-	return len(tilemap.tiles[0])
+// GetLayer returns the tile on a layer, which is EmptyTile if nothing has been placed there.
+func (tilemap *Tilemap) GetLayer(layer Layer, x int, y int) (Tile, bool) {
+	tilemap.mu.RLock()
+	defer tilemap.mu.RUnlock()
+	return tilemap.getLayer(layer, x, y)
 }
 
-func (Tilemap *Tilemap) Get(x int, y int) (Tile, bool) {
-	if x < 0 || x >= len(Tilemap.tiles) || y < 0 || y >= len(Tilemap.tiles[x]) {
+// getLayer must be called with the lock held.
+func (tilemap *Tilemap) getLayer(layer Layer, x int, y int) (Tile, bool) {
+	if layer >= LayerCount || !tilemap.inBounds(x, y) {
 		return Tile{}, false
 	}
 
-	return Tilemap.tiles[x][y], true
+	tiles := tilemap.layers[layer]
+	if tiles == nil {
+		return Tile{Type: EmptyTile}, true
+	}
+	return tiles[x][y], true
+}
+
+// HasLayer reports whether anything has been placed on a layer.
+func (tilemap *Tilemap) HasLayer(layer Layer) bool {
+	tilemap.mu.RLock()
+	defer tilemap.mu.RUnlock()
+	return layer < LayerCount && tilemap.layers[layer] != nil
+}
+
+// SetLayer replaces a whole layer. Passing nil clears a non ground layer. The map keeps
+// tiles, so the caller must not modify it afterwards.
+func (tilemap *Tilemap) SetLayer(layer Layer, tiles [][]Tile) bool {
+	if layer >= LayerCount || (layer == GroundLayer && tiles == nil) {
+		return false
+	}
+	if tiles != nil {
+		if len(tiles) != tilemap.Width() {
+			return false
+		}
+		for x := range tiles {
+			if len(tiles[x]) != tilemap.Height() {
+				return false
+			}
+		}
+	}
+
+	tilemap.mu.Lock()
+	tilemap.layers[layer] = tiles
+	tilemap.mu.Unlock()
+	return true
+}
+
+// NewLayer allocates a layer sized to the map and filled with EmptyTile.
+func (tilemap *Tilemap) NewLayer() [][]Tile {
+	tiles := make([][]Tile, tilemap.Width())
+	for x := range tiles {
+		tiles[x] = make([]Tile, tilemap.Height())
+		for y := range tiles[x] {
+			tiles[x][y] = Tile{Type: EmptyTile}
+		}
+	}
+	return tiles
+}
+
+// Collides reports whether the collision layer blocks a cell.
+func (tilemap *Tilemap) Collides(x int, y int) bool {
+	tile, ok := tilemap.GetLayer(CollisionLayer, x, y)
+	return ok && tile.Type != EmptyTile
+}
+
+// Stamp copies src onto the map with its bottom left tile at (x, y). Cells where src has
+// ground replace the whole destination cell, other non empty tiles are laid on top.
+func (tilemap *Tilemap) Stamp(src *Tilemap, x int, y int) {
+	cells := []Cell{}
+	for sx := 0; sx < src.Width(); sx++ {
+		for sy := 0; sy < src.Height(); sy++ {
+			dx, dy := x+sx, y+sy
+			if !tilemap.inBounds(dx, dy) {
+				continue
+			}
+
+			ground, _ := src.Get(sx, sy)
+			replace := ground.Type != EmptyTile
+			for layer := GroundLayer; layer < LayerCount; layer++ {
+				tile, _ := src.GetLayer(layer, sx, sy)
+				if tile.Type == EmptyTile && !(replace && layer != GroundLayer) {
+					continue
+				}
+				cells = append(cells, Cell{Layer: layer, X: dx, Y: dy, Tile: tile})
+			}
+		}
+	}
+	tilemap.Apply(cells)
 }
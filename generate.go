@@ -0,0 +1,234 @@
+package mmo
+
+import (
+	"gommo/engine/proceduralgeneration"
+	"gommo/engine/random"
+	"gommo/engine/tilemap"
+	"math"
+)
+
+// decorationPurpose names the random.Scatter decorations are rolled from, shared by whole
+// and chunked worlds so both get the same decorations.
+const decorationPurpose = "decoration"
+
+// World holds the generated tilemap along with the per tile data used to generate it.
+// Config has the water and sand levels that were actually used.
+type World struct {
+	Config      WorldConfig
+	Tilemap     *tilemap.Tilemap
+	Heights     *proceduralgeneration.Heightmap
+	Moisture    *proceduralgeneration.Heightmap
+	Temperature *proceduralgeneration.Heightmap
+	biomes      []uint8
+}
+
+func (world *World) Biome(x int, y int) (*Biome, bool) {
+	if !world.Heights.InBounds(x, y) {
+		return nil, false
+	}
+	return Biomes.Get(int(world.biomes[x*world.Heights.Height+y]))
+}
+
+func CreateTilemap(config WorldConfig) *tilemap.Tilemap {
+	return GenerateWorld(config).Tilemap
+}
+
+func GenerateWorld(config WorldConfig) *World {
+	return GenerateWorldParallel(config, proceduralgeneration.DefaultWorkers)
+}
+
+// GenerateWorldParallel samples the noise and decorates the map on the given number of
+// goroutines, one column at a time. The world is identical for any number of workers.
+func GenerateWorldParallel(config WorldConfig, workers int) *World {
+	sampler := newTerrainSampler(config)
+	config = sampler.config
+	mapSize := config.MapSize
+	world := &World{
+		Config:      config,
+		Heights:     proceduralgeneration.NewHeightmap(mapSize, mapSize),
+		Moisture:    proceduralgeneration.NewHeightmap(mapSize, mapSize),
+		Temperature: proceduralgeneration.NewHeightmap(mapSize, mapSize),
+		biomes:      make([]uint8, mapSize*mapSize),
+	}
+
+	tiles := make([][]tilemap.Tile, mapSize)
+	proceduralgeneration.Parallel(mapSize, workers, func(x int) {
+		tiles[x] = make([]tilemap.Tile, mapSize)
+		for y := range tiles[x] {
+			sample := sampler.sample(x, y)
+			world.Heights.Set(x, y, sample.height)
+			world.Moisture.Set(x, y, sample.moisture)
+			world.Temperature.Set(x, y, sample.temperature)
+			world.biomes[x*mapSize+y] = uint8(sample.biome)
+			tiles[x][y] = tilemap.Tile{Type: sample.tileType}
+		}
+	})
+
+	streams := random.New(config.Seed)
+	world.Tilemap = tilemap.New(tiles, config.TileSize)
+	addRiversAndLakes(world, config, streams)
+	decorate(world, streams, workers)
+	addSettlements(world, config, streams)
+	// Last, as rivers can cut new islets off the coast
+	removeIslets(world.Tilemap)
+	return world
+}
+
+// CreateChunkedTilemap generates the island one chunk at a time as it is accessed. Terrain
+// and decoration only depend on the tile itself, so they match GenerateWorld. Removing
+// islets, rivers and lakes, towns and region names all need the whole map, so chunked
+// worlds don't have them, and objects are only placed by ObjectPlacer, never as entities.
+func CreateChunkedTilemap(config WorldConfig) *tilemap.ChunkedTilemap {
+	sampler := newTerrainSampler(config)
+	decorations := random.New(config.Seed).Scatter(decorationPurpose)
+	size := config.MapSize
+	return tilemap.NewChunked(size, size, config.TileSize, tilemap.DefaultChunkSize, func(x, y int) [tilemap.LayerCount]tilemap.Tile {
+		tiles := [tilemap.LayerCount]tilemap.Tile{}
+		for layer := range tiles {
+			tiles[layer] = tilemap.Tile{Type: tilemap.EmptyTile}
+		}
+
+		sample := sampler.sample(x, y)
+		tiles[tilemap.GroundLayer] = tilemap.Tile{Type: sample.tileType}
+		biome, ok := Biomes.Get(sample.biome)
+		if ok {
+			tiles[tilemap.DecorationLayer], tiles[tilemap.CollisionLayer] = decorationAt(biome, sample.tileType, decorations, x, y)
+		}
+		return tiles
+	})
+}
+
+type terrainSampler struct {
+	config      WorldConfig
+	terrain     proceduralgeneration.Noise
+	moisture    *proceduralgeneration.NoiseMap
+	temperature *proceduralgeneration.NoiseMap
+}
+
+type terrainSample struct {
+	height      float64
+	moisture    float64
+	temperature float64
+	biome       int
+	tileType    tilemap.TileType
+}
+
+const (
+	histogramBins    = 1024
+	histogramSamples = 256
+)
+
+// Moisture and temperature use their own seeds derived from the world seed, so they
+// don't line up with the height noise.
+func newTerrainSampler(config WorldConfig) *terrainSampler {
+	streams := random.New(config.Seed)
+	sampler := &terrainSampler{
+		config:      config,
+		terrain:     config.terrain(),
+		moisture:    proceduralgeneration.NewNoiseMap(streams.Seed(random.Key{Purpose: "climate/moisture"}), loadClimateOctaves(), 1),
+		temperature: proceduralgeneration.NewNoiseMap(streams.Seed(random.Key{Purpose: "climate/temperature"}), loadClimateOctaves(), 1),
+	}
+	if config.WaterPercent > 0 {
+		sampler.levelsFromPercentages()
+	}
+	return sampler
+}
+
+// levelsFromPercentages sets the water and sand levels from the heights of an evenly
+// spaced grid of tiles.
+func (sampler *terrainSampler) levelsFromPercentages() {
+	step := sampler.config.MapSize / histogramSamples
+	if step < 1 {
+		step = 1
+	}
+	histogram := proceduralgeneration.NewHistogram(histogramBins)
+	for x := 0; x < sampler.config.MapSize; x += step {
+		for y := 0; y < sampler.config.MapSize; y += step {
+			histogram.Add(sampler.height(x, y))
+		}
+	}
+	sampler.config.WaterLevel = histogram.Percentile(sampler.config.WaterPercent / 100)
+	sampler.config.SandLevel = histogram.Percentile((sampler.config.WaterPercent + sampler.config.SandPercent) / 100)
+}
+
+func (sampler *terrainSampler) height(x int, y int) float64 {
+	height := proceduralgeneration.Unit(sampler.terrain.Eval(float64(x), float64(y)))
+	height = modifyHeightForIsland(sampler.config.MapSize, sampler.config.IslandExponent, height, x, y)
+	return proceduralgeneration.Unit(height)
+}
+
+func (sampler *terrainSampler) sample(x int, y int) terrainSample {
+	height := sampler.height(x, y)
+	terrainType := sampler.config.terrainType(height)
+
+	sample := terrainSample{
+		height:      height,
+		moisture:    sampler.moisture.Get(x, y),
+		temperature: sampler.temperature.Get(x, y),
+		tileType:    terrainType,
+	}
+
+	biome, ok := Biomes.Find(terrainType, sample.moisture, sample.temperature)
+	if ok {
+		sample.biome = biome
+		sample.tileType = Biomes.Biomes[biome].groundType
+	}
+	return sample
+}
+
+// decorate scatters each biome's decorations over its tiles. Decorations that aren't
+// walkable are copied to the collision layer.
+func decorate(world *World, streams random.Streams, workers int) {
+	tmap := world.Tilemap
+	decorations := streams.Scatter(decorationPurpose)
+	decoration := tmap.NewLayer()
+	collision := tmap.NewLayer()
+	proceduralgeneration.Parallel(tmap.Width(), workers, func(x int) {
+		for y := 0; y < tmap.Height(); y++ {
+			tile, _ := tmap.Get(x, y)
+			biome, ok := world.Biome(x, y)
+			if ok {
+				decoration[x][y], collision[x][y] = decorationAt(biome, tile.Type, decorations, x, y)
+			}
+		}
+	})
+	tmap.SetLayer(tilemap.DecorationLayer, decoration)
+	tmap.SetLayer(tilemap.CollisionLayer, collision)
+}
+
+// decorationAt returns the decoration of a tile and what it puts on the collision layer,
+// both EmptyTile when there is none. Tiles whose ground isn't their biome's, like rivers,
+// aren't decorated. Each tile is decided on its own, so chunks can be decorated alone.
+func decorationAt(biome *Biome, ground tilemap.TileType, decorations random.Scatter, x int, y int) (tilemap.Tile, tilemap.Tile) {
+	empty := tilemap.Tile{Type: tilemap.EmptyTile}
+	if ground != biome.groundType {
+		return empty, empty
+	}
+	decorationType, ok := biome.Decoration(decorations.Roll(x, y))
+	if !ok {
+		return empty, empty
+	}
+	decoration := tilemap.Tile{Type: decorationType}
+	if !Tiles.Walkable(decorationType) {
+		return decoration, decoration
+	}
+	return decoration, empty
+}
+
+func loadClimateOctaves() []proceduralgeneration.Octave {
+	octaves := []proceduralgeneration.Octave{
+		{Frequency: 0.004, Scale: 0.7},
+		{Frequency: 0.02, Scale: 0.25},
+		{Frequency: 0.08, Scale: 0.05},
+	}
+	return octaves
+}
+
+func modifyHeightForIsland(mapSize int, islandExponent float64, height float64, x int, y int) float64 {
+	dx := float64(x)/float64(mapSize) - 0.5
+	dy := float64(y)/float64(mapSize) - 0.5
+	d := math.Sqrt(dx*dx+dy*dy) * 2
+	d = math.Pow(d, islandExponent)
+	height = (1 - d + height) / 2
+	return height
+}
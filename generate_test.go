@@ -0,0 +1,111 @@
+package mmo
+
+import (
+	"fmt"
+	"gommo/engine/ecs"
+	"gommo/engine/physics"
+	"gommo/engine/proceduralgeneration"
+	"gommo/engine/random"
+	"gommo/engine/tilemap"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// testMapSize keeps worlds small enough to generate many times, while still leaving room
+// for rivers and towns.
+const testMapSize = 256
+
+func testWorldConfig() WorldConfig {
+	config := DefaultWorldConfig
+	config.MapSize = testMapSize
+	return config
+}
+
+// parallelWorkers is the worker count compared against a single worker, forced above one
+// so the comparison means something on single CPU machines.
+func parallelWorkers() int {
+	if proceduralgeneration.DefaultWorkers == 1 {
+		return 4
+	}
+	return proceduralgeneration.DefaultWorkers
+}
+
+func TestGenerateWorldParallelDeterministic(t *testing.T) {
+	config := testWorldConfig()
+	sequential := GenerateWorldParallel(config, 1)
+	parallel := GenerateWorldParallel(config, parallelWorkers())
+
+	for layer := tilemap.Layer(0); layer < tilemap.LayerCount; layer++ {
+		for x := 0; x < config.MapSize; x++ {
+			for y := 0; y < config.MapSize; y++ {
+				want, _ := sequential.Tilemap.GetLayer(layer, x, y)
+				got, _ := parallel.Tilemap.GetLayer(layer, x, y)
+				if got != want {
+					t.Fatalf("layer %d tile (%d, %d) is %v with %d workers, want %v", layer, x, y, got, parallelWorkers(), want)
+				}
+			}
+		}
+	}
+	for x := 0; x < config.MapSize; x++ {
+		for y := 0; y < config.MapSize; y++ {
+			if sequential.Heights.Get(x, y) != parallel.Heights.Get(x, y) {
+				t.Fatalf("height (%d, %d) differs with %d workers", x, y, parallelWorkers())
+			}
+		}
+	}
+}
+
+func TestPlaceObjectsParallelDeterministic(t *testing.T) {
+	config := testWorldConfig()
+	tmap := GenerateWorldParallel(config, 1).Tilemap
+
+	placed := func(workers int) []string {
+		engine := ecs.NewEngine()
+		ecs.WriteResource(engine, random.New(config.Seed))
+		PlaceObjectsParallel(engine, tmap, config, workers)
+
+		objects := []string{}
+		ecs.Each(engine, WorldObject{}, func(id ecs.Id, a interface{}) {
+			transform := physics.Transform{}
+			ecs.Read(engine, id, &transform)
+			objects = append(objects, fmt.Sprint(id, a.(WorldObject).Name, transform.X, transform.Y))
+		})
+		sort.Strings(objects)
+		return objects
+	}
+
+	want := placed(1)
+	got := placed(parallelWorkers())
+	if len(want) == 0 {
+		t.Fatal("no objects were placed")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("%d objects placed with %d workers differ from %d placed with 1 worker", len(got), parallelWorkers(), len(want))
+	}
+}
+
+func BenchmarkGenerateWorld(b *testing.B) {
+	config := testWorldConfig()
+	for _, workers := range []int{1, parallelWorkers()} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				GenerateWorldParallel(config, workers)
+			}
+		})
+	}
+}
+
+func BenchmarkPlaceObjects(b *testing.B) {
+	config := testWorldConfig()
+	tmap := GenerateWorldParallel(config, 1).Tilemap
+	for _, workers := range []int{1, parallelWorkers()} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				engine := ecs.NewEngine()
+				ecs.WriteResource(engine, random.New(config.Seed))
+				PlaceObjectsParallel(engine, tmap, config, workers)
+			}
+		})
+	}
+}
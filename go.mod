@@ -4,7 +4,7 @@ go 1.16
 
 require (
 	github.com/faiface/pixel v0.10.0
-	github.com/ojrac/opensimplex-go v1.0.2 // indirect
-	github.com/unitoftime/packer v0.0.0-20211214011341-d04bb2072d16 // indirect
-	nhooyr.io/websocket v1.8.7 // indirect
+	github.com/ojrac/opensimplex-go v1.0.2
+	github.com/unitoftime/packer v0.0.0-20211214011341-d04bb2072d16
+	nhooyr.io/websocket v1.8.7
 )
@@ -0,0 +1,105 @@
+package mmo
+
+import (
+	"gommo/engine/proceduralgeneration"
+	"gommo/engine/random"
+	"gommo/engine/tilemap"
+)
+
+const (
+	riverSourceHeight = 0.78
+	riverSourceChance = 0.001
+	// Rivers widen as the length of river upstream of them grows
+	riverWideFlow  = 80
+	riverDeepFlow  = 400
+	lakeDepth      = 0.05
+	lakeShoreDepth = 0.035
+)
+
+// addRiversAndLakes runs water over the world's heights. Rivers start on high ground and
+// follow the drainage down to the sea, and the bottoms of deep depressions become lakes.
+// Deep water is WaterTile, while narrow rivers and lake shores are walkable ShallowsTile.
+func addRiversAndLakes(world *World, config WorldConfig, streams random.Streams) {
+	tmap := world.Tilemap
+	sources := streams.Scatter("rivers/sources")
+	drainage := proceduralgeneration.NewDrainage(world.Heights, config.WaterLevel)
+	water := tmap.NewLayer()
+
+	flow := proceduralgeneration.NewHeightmap(tmap.Width(), tmap.Height())
+	for x := 0; x < tmap.Width(); x++ {
+		for y := 0; y < tmap.Height(); y++ {
+			if world.Heights.Get(x, y) < riverSourceHeight || sources.Roll(x, y) >= riverSourceChance {
+				continue
+			}
+			length := 0.0
+			drainage.Trace(x, y, func(x, y int) bool {
+				length++
+				flow.Set(x, y, flow.Get(x, y)+length)
+				return true
+			})
+		}
+	}
+
+	for x := 0; x < tmap.Width(); x++ {
+		for y := 0; y < tmap.Height(); y++ {
+			depth := drainage.Depth(x, y)
+			if depth >= lakeDepth {
+				setWater(water, tilemap.Point{X: x, Y: y}, WaterTile)
+			} else if depth >= lakeShoreDepth {
+				setWater(water, tilemap.Point{X: x, Y: y}, ShallowsTile)
+			}
+
+			f := flow.Get(x, y)
+			if f == 0 {
+				continue
+			}
+			center := tilemap.Point{X: x, Y: y}
+			switch {
+			case f >= riverDeepFlow:
+				carveRiver(water, center, 2)
+			case f >= riverWideFlow:
+				carveRiver(water, center, 1)
+			default:
+				setWater(water, center, ShallowsTile)
+			}
+		}
+	}
+
+	cells := []tilemap.Cell{}
+	for x := range water {
+		for y := range water[x] {
+			tile, _ := tmap.Get(x, y)
+			if water[x][y].Type == tilemap.EmptyTile || tile.Type == WaterTile {
+				continue
+			}
+			cells = append(cells, tilemap.Cell{Layer: tilemap.GroundLayer, X: x, Y: y, Tile: water[x][y]})
+		}
+	}
+	tmap.Apply(cells)
+}
+
+// carveRiver fills the tiles within radius of center with shallows, with deep water in the
+// middle of the widest rivers.
+func carveRiver(water [][]tilemap.Tile, center tilemap.Point, radius int) {
+	for dx := -radius; dx <= radius; dx++ {
+		for dy := -radius; dy <= radius; dy++ {
+			d := dx*dx + dy*dy
+			if d > radius*radius {
+				continue
+			}
+			tileType := WaterTile
+			if radius < 2 || d > (radius-1)*(radius-1) {
+				tileType = ShallowsTile
+			}
+			setWater(water, tilemap.Point{X: center.X + dx, Y: center.Y + dy}, tileType)
+		}
+	}
+}
+
+// setWater never turns deep water back into shallows where rivers and lakes overlap.
+func setWater(water [][]tilemap.Tile, p tilemap.Point, tileType tilemap.TileType) {
+	if p.X < 0 || p.X >= len(water) || p.Y < 0 || p.Y >= len(water[p.X]) || water[p.X][p.Y].Type == WaterTile {
+		return
+	}
+	water[p.X][p.Y] = tilemap.Tile{Type: tileType}
+}
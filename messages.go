@@ -0,0 +1,175 @@
+package mmo
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"gommo/engine/ecs"
+	"gommo/engine/network"
+	"gommo/engine/physics"
+	"gommo/engine/tilemap"
+)
+
+// MessageType is the type byte of every network.Message, saying what its payload holds.
+type MessageType uint8
+
+const (
+	// PingMessage keeps an idle client from timing out, its payload is ignored
+	PingMessage MessageType = iota + 1
+	// SpawnMessage holds the physics.Transform the server placed the client's player at
+	SpawnMessage
+	// TileChangeMessage holds a tilemap.Change made on the server
+	TileChangeMessage
+	// InputMessage holds a physics.SequencedInput for the client's player. The server is
+	// authoritative for movement, so clients send inputs instead of positions.
+	InputMessage
+	// InputAckMessage holds the physics.InputAck the server replies to each input with
+	InputAckMessage
+	// EntityTransformMessage holds an EntityTransform of another player, for the client to
+	// interpolate between
+	EntityTransformMessage
+	// EntityRemovedMessage holds the EntityRemoval of a player that left
+	EntityRemovedMessage
+	// WorldMessage holds the WorldState, sent before anything else so the client plays on
+	// exactly the world the server has
+	WorldMessage
+)
+
+const (
+	entityIdSize        = 4
+	EntityTransformSize = entityIdSize + physics.TransformSize
+	EntityRemovalSize   = entityIdSize
+)
+
+// EntityTransform is where the server has an entity, the id is the server's and means
+// nothing to the client's engine.
+type EntityTransform struct {
+	Id ecs.Id
+	physics.Transform
+}
+
+func (entity EntityTransform) MarshalBinary() ([]byte, error) {
+	data := make([]byte, entityIdSize, EntityTransformSize)
+	binary.LittleEndian.PutUint32(data, uint32(entity.Id))
+	transform, _ := entity.Transform.MarshalBinary()
+	return append(data, transform...), nil
+}
+
+func (entity *EntityTransform) UnmarshalBinary(data []byte) error {
+	if len(data) != EntityTransformSize {
+		return errors.New("invalid entity transform size")
+	}
+	entity.Id = ecs.Id(binary.LittleEndian.Uint32(data))
+	return entity.Transform.UnmarshalBinary(data[entityIdSize:])
+}
+
+// EntityRemoval tells the client to forget a server entity.
+type EntityRemoval struct {
+	Id ecs.Id
+}
+
+func (removal EntityRemoval) MarshalBinary() ([]byte, error) {
+	data := make([]byte, EntityRemovalSize)
+	binary.LittleEndian.PutUint32(data, uint32(removal.Id))
+	return data, nil
+}
+
+func (removal *EntityRemoval) UnmarshalBinary(data []byte) error {
+	if len(data) != EntityRemovalSize {
+		return errors.New("invalid entity removal size")
+	}
+	removal.Id = ecs.Id(binary.LittleEndian.Uint32(data))
+	return nil
+}
+
+// EncodeMessage frames a payload as a message of the given type, ready to be written.
+func EncodeMessage(msgType MessageType, payload encoding.BinaryMarshaler) ([]byte, error) {
+	data, err := payload.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return network.Message{Type: uint8(msgType), Payload: data}.MarshalBinary()
+}
+
+// WorldState is the server's world as it is right now: the config objects, regions and
+// spawns are derived from, and the tilemap including every edit made since it was
+// generated or loaded. Chunked worlds can't be edited, so only their config is sent.
+type WorldState struct {
+	Config  WorldConfig
+	Tilemap tilemap.Map
+}
+
+// MarshalBinary encodes a uint32 length and the config as JSON, followed by the tilemap in
+// its file format unless the world is chunked.
+func (world WorldState) MarshalBinary() ([]byte, error) {
+	config, err := json.Marshal(world.Config)
+	if err != nil {
+		return nil, err
+	}
+	buf := bytes.Buffer{}
+	err = binary.Write(&buf, binary.LittleEndian, uint32(len(config)))
+	if err != nil {
+		return nil, err
+	}
+	buf.Write(config)
+	if world.Config.Chunked {
+		return buf.Bytes(), nil
+	}
+
+	whole, ok := world.Tilemap.(*tilemap.Tilemap)
+	if !ok {
+		return nil, errors.New("world state: only chunked worlds can be sent without their tilemap")
+	}
+	err = tilemap.Save(&buf, whole, world.Config.Seed)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (world *WorldState) UnmarshalBinary(data []byte) error {
+	if len(data) < 4 {
+		return errors.New("invalid world state size")
+	}
+	length := binary.LittleEndian.Uint32(data)
+	data = data[4:]
+	if uint64(length) > uint64(len(data)) {
+		return errors.New("invalid world state size")
+	}
+
+	config := WorldConfig{}
+	err := json.Unmarshal(data[:length], &config)
+	if err != nil {
+		return err
+	}
+	data = data[length:]
+
+	if config.Chunked {
+		if len(data) != 0 {
+			return errors.New("invalid world state size")
+		}
+		err = config.Validate()
+		if err != nil {
+			return err
+		}
+		world.Config = config
+		world.Tilemap = CreateChunkedTilemap(config)
+		return nil
+	}
+
+	tmap, header, err := tilemap.Load(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	config.Seed = header.Seed
+	err = config.Validate()
+	if err != nil {
+		return err
+	}
+
+	world.Config = config
+	world.Tilemap = tmap
+	return nil
+}
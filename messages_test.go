@@ -0,0 +1,59 @@
+package mmo
+
+import (
+	"gommo/engine/tilemap"
+	"reflect"
+	"testing"
+)
+
+func TestWorldStateMarshal(t *testing.T) {
+	config := testWorldConfig()
+	chunked := config
+	chunked.Chunked = true
+
+	tests := []struct {
+		name  string
+		world WorldState
+	}{
+		{"whole", WorldState{Config: config, Tilemap: GenerateWorld(config).Tilemap}},
+		{"chunked", WorldState{Config: chunked, Tilemap: CreateChunkedTilemap(chunked)}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := test.world.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := WorldState{}
+			err = got.UnmarshalBinary(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got.Config, test.world.Config) {
+				t.Fatalf("got config %+v, want %+v", got.Config, test.world.Config)
+			}
+
+			want := test.world.Tilemap
+			if got.Tilemap.Width() != want.Width() || got.Tilemap.Height() != want.Height() {
+				t.Fatalf("got a %dx%d map, want %dx%d", got.Tilemap.Width(), got.Tilemap.Height(), want.Width(), want.Height())
+			}
+			for layer := tilemap.Layer(0); layer < tilemap.LayerCount; layer++ {
+				for x := 0; x < want.Width(); x++ {
+					for y := 0; y < want.Height(); y++ {
+						gotTile, _ := got.Tilemap.GetLayer(layer, x, y)
+						wantTile, _ := want.GetLayer(layer, x, y)
+						if gotTile != wantTile {
+							t.Fatalf("layer %s tile (%d, %d) is %v, want %v", layer, x, y, gotTile, wantTile)
+						}
+					}
+				}
+			}
+
+			for _, n := range []int{0, 3, 4, len(data) / 2, len(data) - 1} {
+				if (&WorldState{}).UnmarshalBinary(data[:n]) == nil {
+					t.Fatalf("world state truncated to %d of %d bytes decoded without an error", n, len(data))
+				}
+			}
+		})
+	}
+}
@@ -1,109 +1,200 @@
 package mmo
 
 import (
+	"embed"
+	"errors"
+	"fmt"
+	"gommo/engine/asset"
 	"gommo/engine/ecs"
 	"gommo/engine/physics"
-	"gommo/engine/proceduralgeneration"
+	"gommo/engine/random"
+	"gommo/engine/tiled"
 	"gommo/engine/tilemap"
-	"math"
+	"io/fs"
+	"os"
 	"time"
 )
 
-const (
-	exponent       = 0.8
-	islandExponent = 2.0
-)
+//go:embed assets
+var assets embed.FS
 
-const (
-	GrassTile tilemap.TileType = iota
-	SandTile
-	WaterTile
-	tileSize = 16
-	mapSize  = 1000
-)
+const tilesJson = "assets/tiles.json"
 
-var seed = int64(12345)
+// Tiles describes every TileType used by the generator, physics and renderer.
+var Tiles = loadTiles()
 
-func LoadGame(engine *ecs.Engine) (*tilemap.Tilemap, ecs.Id, ecs.Id) {
-	tmap := CreateTilemap(seed, mapSize, tileSize)
+func loadTiles() *tilemap.Registry {
+	registry, err := asset.NewLoad(assets).TileRegistry(tilesJson)
+	if err != nil {
+		panic(err)
+	}
+	return registry
+}
 
-	spawnPoint := createSpawnPoint()
-	purpleGemId := engine.NewId()
-	ecs.Write(engine, purpleGemId, spawnPoint)
-	ecs.Write(engine, purpleGemId, physics.Input{})
+// Tile types the game refers to directly. They are looked up by name, so the type numbers
+// only live in tiles.json.
+var (
+	GrassTile    = tileNamed("grass")
+	SandTile     = tileNamed("sand")
+	WaterTile    = tileNamed("water")
+	FlowerTile   = tileNamed("flower")
+	RockTile     = tileNamed("rock")
+	SnowTile     = tileNamed("snow")
+	SwampTile    = tileNamed("swamp")
+	ForestTile   = tileNamed("forest")
+	ShallowsTile = tileNamed("shallows")
+	StoneTile    = tileNamed("stone")
+	WallTile     = tileNamed("wall")
+	RoadTile     = tileNamed("road")
+	BridgeTile   = tileNamed("bridge")
+	PlazaTile    = tileNamed("plaza")
+	BuildingTile = tileNamed("building")
+)
 
-	redGemId := engine.NewId()
-	ecs.Write(engine, redGemId, spawnPoint)
-	ecs.Write(engine, redGemId, physics.Input{})
+func tileNamed(name string) tilemap.TileType {
+	tileType, ok := Tiles.ByName(name)
+	if !ok {
+		panic(fmt.Errorf("%s has no %q tile", tilesJson, name))
+	}
+	return tileType
+}
 
+func LoadGame(engine *ecs.Engine, config WorldConfig) (*tilemap.Tilemap, ecs.Id, ecs.Id) {
+	tmap := CreateTilemap(config)
+	purpleGemId, redGemId := LoadGameWithTilemap(engine, tmap, config)
 	return tmap, purpleGemId, redGemId
 }
 
-func createSpawnPoint() physics.Transform {
-	return physics.Transform{X: float64((tileSize * mapSize) / 2), Y: float64((tileSize * mapSize) / 2)}
+func LoadGameWithTilemap(engine *ecs.Engine, tmap tilemap.Map, config WorldConfig) (ecs.Id, ecs.Id) {
+	ecs.WriteResource(engine, random.New(config.Seed))
+	// Both need the whole map, see CreateChunkedTilemap
+	whole, ok := tmap.(*tilemap.Tilemap)
+	if ok {
+		nameRegions(engine, whole)
+		PlaceObjects(engine, whole, config)
+	}
+
+	spawner := NewSpawner(tmap, config, WorldStreams(engine))
+	purpleGemId := spawnPlayer(engine, spawner)
+	redGemId := spawnPlayer(engine, spawner)
+
+	return purpleGemId, redGemId
+}
+
+// WorldStreams returns the random streams of the world loaded into the engine, which every
+// generation site draws from.
+func WorldStreams(engine *ecs.Engine) random.Streams {
+	streams := random.Streams{}
+	ecs.ReadResource(engine, &streams)
+	return streams
 }
 
-func CreateTilemap(seed int64, mapSize int, tileSize int) *tilemap.Tilemap {
-	octaves := loadOctaves()
-	terrain := proceduralgeneration.NewNoiseMap(seed, octaves, exponent)
-
-	tiles := make([][]tilemap.Tile, mapSize)
-	for x := range tiles {
-		tiles[x] = make([]tilemap.Tile, mapSize)
-		for y := range tiles[x] {
-			height := terrain.Get(x, y)
-			height = modifyHeightForIsland(mapSize, height, x, y)
-			tileType := findTerrainTileType(height)
-			tiles[x][y] = tilemap.Tile{Type: tileType}
+// LoadTilemap loads a saved map, generating the island from config instead if there is
+// no path or the file doesn't exist yet. It returns config with the seed the map was
+// generated with. Chunked worlds are never held whole, so they can't be loaded or saved.
+func LoadTilemap(path string, config WorldConfig) (tilemap.Map, WorldConfig, error) {
+	if config.Chunked {
+		if path != "" {
+			return nil, config, errors.New("chunked worlds can't be loaded from or saved to a file")
 		}
+		return CreateChunkedTilemap(config), config, nil
+	}
+	if path == "" {
+		return CreateTilemap(config), config, nil
+	}
+	tmap, header, err := tilemap.LoadFile(path)
+	if os.IsNotExist(err) {
+		return CreateTilemap(config), config, nil
+	}
+	if err != nil {
+		return nil, config, err
 	}
+	config.Seed = header.Seed
+	return tmap, config, nil
+}
+
+func SaveTilemap(path string, tmap *tilemap.Tilemap, seed int64) error {
+	return tilemap.SaveFile(path, tmap, seed)
+}
 
-	return tilemap.New(tiles, tileSize)
+type SpawnMarker struct {
+	Name       string
+	Type       string
+	Properties map[string]string
 }
 
-func loadOctaves() []proceduralgeneration.Octave {
-	octaves := []proceduralgeneration.Octave{
-		{Frequency: 0.02, Scale: 0.6},
-		{Frequency: 0.05, Scale: 0.3},
-		{Frequency: 0.1, Scale: 0.07},
-		{Frequency: 0.2, Scale: 0.02},
-		{Frequency: 0.4, Scale: 0.01},
+func (marker *SpawnMarker) ComponentSet(val interface{}) { *marker = val.(SpawnMarker) }
+
+// StampTiledMap imports a map authored in Tiled, stamps it onto tmap with its bottom left
+// tile at (x, y) and creates a SpawnMarker entity for every object it contains.
+func StampTiledMap(engine *ecs.Engine, tmap *tilemap.Tilemap, fsys fs.FS, path string, x int, y int) error {
+	tiledMap, err := tiled.Load(fsys, path, Tiles.BySprite)
+	if err != nil {
+		return err
+	}
+	if tiledMap.Tilemap.TileSize != tmap.TileSize {
+		return fmt.Errorf("tiled map %s uses %dpx tiles, expected %dpx", path, tiledMap.Tilemap.TileSize, tmap.TileSize)
+	}
+
+	tmap.Stamp(tiledMap.Tilemap, x, y)
+
+	offsetX, offsetY := tmap.TileToWorld(x, y)
+	for _, spawn := range tiledMap.Spawns {
+		id := engine.NewId()
+		ecs.Write(engine, id, physics.Transform{X: spawn.X + offsetX, Y: spawn.Y + offsetY})
+		ecs.Write(engine, id, SpawnMarker{Name: spawn.Name, Type: spawn.Type, Properties: spawn.Properties})
 	}
-	return octaves
+	return nil
 }
 
-func findTerrainTileType(height float64) tilemap.TileType {
-	const waterLevel = 0.5
-	const sandLevel = waterLevel + 0.1
-	var tileType tilemap.TileType
-	if height < waterLevel {
-		tileType = WaterTile
-	} else if height < sandLevel {
-		tileType = SandTile
-	} else {
-		tileType = GrassTile
+func spawnPlayer(engine *ecs.Engine, spawner *Spawner) ecs.Id {
+	spawnPoint, err := spawner.Spawn("")
+	if err != nil {
+		// A map without any land still needs somewhere to put the player
+		center := spawner.tilemap.CenterTile()
+		x, y := spawner.tilemap.TileToWorld(center.X, center.Y)
+		spawnPoint = physics.Transform{X: x, Y: y}
 	}
-	return tileType
+	id := engine.NewId()
+	ecs.Write(engine, id, spawnPoint)
+	ecs.Write(engine, id, physics.Input{})
+	return id
+}
+
+func TileCost(tile tilemap.Tile) (float64, bool) {
+	return Tiles.Cost(tile)
 }
 
-func modifyHeightForIsland(mapSize int, height float64, x int, y int) float64 {
-	dx := float64(x)/float64(mapSize) - 0.5
-	dy := float64(y)/float64(mapSize) - 0.5
-	d := math.Sqrt(dx*dx+dy*dy) * 2
-	d = math.Pow(d, islandExponent)
-	height = (1 - d + height) / 2
-	return height
+// NewMovement keeps entities within the map and off tiles that the registry says aren't
+// walkable or that are blocked on the collision layer.
+func NewMovement(tmap tilemap.Map) physics.Movement {
+	return physics.Movement{
+		Bounds: physics.BoundsFromTilemap(tmap),
+		Blocked: func(x float64, y float64) bool {
+			tileX, tileY := tmap.WorldToTile(x, y)
+			tile, ok := tmap.Get(tileX, tileY)
+			return !ok || !Tiles.Walkable(tile.Type) || tmap.Collides(tileX, tileY)
+		},
+	}
 }
 
-func CreatePhysicsSystems(engine *ecs.Engine) []ecs.System {
+func CreatePhysicsSystems(engine *ecs.Engine, tmap tilemap.Map) []ecs.System {
+	movement := NewMovement(tmap)
 	physicsSystems := []ecs.System{
-		{Name: "HandleInput", Func: handleInputFunc(engine)},
+		{Name: "HandleInput", Func: handleInputFunc(engine, movement)},
+		{Name: "EnforceBounds", Func: enforceBoundsFunc(engine, movement.Bounds)},
 	}
 	return physicsSystems
 }
 
-func handleInputFunc(engine *ecs.Engine) func(dt time.Duration) {
+func handleInputFunc(engine *ecs.Engine, movement physics.Movement) func(dt time.Duration) {
+	return func(dt time.Duration) {
+		physics.HandleInput(engine, movement)
+	}
+}
+
+func enforceBoundsFunc(engine *ecs.Engine, bounds physics.Bounds) func(dt time.Duration) {
 	return func(dt time.Duration) {
-		physics.HandleInput(engine)
+		physics.EnforceBounds(engine, bounds)
 	}
 }
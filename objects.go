@@ -0,0 +1,193 @@
+package mmo
+
+import (
+	"fmt"
+	"gommo/engine/asset"
+	"gommo/engine/ecs"
+	"gommo/engine/physics"
+	"gommo/engine/proceduralgeneration"
+	"gommo/engine/random"
+	"gommo/engine/tilemap"
+	"math"
+)
+
+const objectsJson = "assets/objects.json"
+
+// ObjectDefinition describes something the generator scatters over the world. Objects of
+// one definition are at least Radius tiles apart, only stand on the listed ground tiles,
+// and Density gives the chance of keeping each spot in a biome. Biomes that aren't listed
+// get none.
+type ObjectDefinition struct {
+	Name    string             `json:"name"`
+	Kind    string             `json:"kind"`
+	Sprite  string             `json:"sprite"`
+	Radius  float64            `json:"radius"`
+	Tiles   []string           `json:"tiles"`
+	Density map[string]float64 `json:"density"`
+
+	tileTypes map[tilemap.TileType]bool
+}
+
+// Objects lists every object the generator places, in the order they claim tiles.
+var Objects = loadObjects()
+
+func loadObjects() []ObjectDefinition {
+	definitions := []ObjectDefinition{}
+	err := asset.NewLoad(assets).Json(objectsJson, &definitions)
+	if err != nil {
+		panic(err)
+	}
+
+	for i := range definitions {
+		definition := &definitions[i]
+		if definition.Radius <= 0 || definition.Radius >= tilemap.DefaultChunkSize {
+			panic(fmt.Errorf("object %q radius must be between 0 and %d", definition.Name, tilemap.DefaultChunkSize))
+		}
+		definition.tileTypes = make(map[tilemap.TileType]bool)
+		for _, name := range definition.Tiles {
+			tileType, ok := Tiles.ByName(name)
+			if !ok {
+				panic(fmt.Errorf("object %q uses unknown tile %q", definition.Name, name))
+			}
+			definition.tileTypes[tileType] = true
+		}
+	}
+	return definitions
+}
+
+func ObjectByName(name string) (*ObjectDefinition, bool) {
+	for i := range Objects {
+		if Objects[i].Name == name {
+			return &Objects[i], true
+		}
+	}
+	return nil, false
+}
+
+// WorldObject is a tree, rock or other object placed by the generator.
+type WorldObject struct {
+	Name string
+	Kind string
+}
+
+func (object *WorldObject) ComponentSet(val interface{}) { *object = val.(WorldObject) }
+
+type PlacedObject struct {
+	Definition *ObjectDefinition
+	Tile       tilemap.Point
+	X, Y       float64
+}
+
+// ObjectPlacer places objects one chunk of tiles at a time. Every chunk only depends on
+// the world config and the tilemap, so the client and server place the same objects
+// without sending them.
+type ObjectPlacer struct {
+	tmap      tilemap.Map
+	sampler   *terrainSampler
+	densities []random.Scatter
+	layers    []*proceduralgeneration.ChunkedPoisson
+}
+
+func NewObjectPlacer(tmap tilemap.Map, config WorldConfig, streams random.Streams) *ObjectPlacer {
+	placer := &ObjectPlacer{
+		tmap:    tmap,
+		sampler: newTerrainSampler(config),
+	}
+	for _, definition := range Objects {
+		// Naming purposes after the object keeps objects in place when definitions are added
+		// or reordered
+		purpose := "objects/" + definition.Name
+		placer.densities = append(placer.densities, streams.Scatter(purpose+"/density"))
+		placer.layers = append(placer.layers, proceduralgeneration.NewChunkedPoisson(streams, purpose, tilemap.DefaultChunkSize, definition.Radius))
+	}
+	return placer
+}
+
+// Chunk returns the objects in the chunk of DefaultChunkSize tiles at (chunkX, chunkY).
+// Each tile holds at most one object, going to the definition listed first.
+func (placer *ObjectPlacer) Chunk(chunkX int, chunkY int) []PlacedObject {
+	placed := []PlacedObject{}
+	occupied := make(map[tilemap.Point]bool)
+	for i := range Objects {
+		definition, density := &Objects[i], placer.densities[i]
+		if !placer.chunkHasTiles(definition, chunkX, chunkY) {
+			continue
+		}
+		keep := func(p proceduralgeneration.Point) bool {
+			return placer.allowed(definition, density, pointTile(p))
+		}
+		for _, p := range placer.layers[i].Chunk(chunkX, chunkY, keep) {
+			tile := pointTile(p)
+			if occupied[tile] {
+				continue
+			}
+			occupied[tile] = true
+
+			// Poisson points are in tile units with tiles spanning [x, x+1), while tiles are
+			// drawn centered on x*TileSize
+			ts := placer.tmap.TileBounds(0, 0).Width()
+			placed = append(placed, PlacedObject{
+				Definition: definition,
+				Tile:       tile,
+				X:          (p.X - 0.5) * ts,
+				Y:          (p.Y - 0.5) * ts,
+			})
+		}
+	}
+	return placed
+}
+
+// chunkHasTiles skips sampling chunks, like open sea, where the object can't be placed.
+func (placer *ObjectPlacer) chunkHasTiles(definition *ObjectDefinition, chunkX int, chunkY int) bool {
+	for x := chunkX * tilemap.DefaultChunkSize; x < (chunkX+1)*tilemap.DefaultChunkSize; x++ {
+		for y := chunkY * tilemap.DefaultChunkSize; y < (chunkY+1)*tilemap.DefaultChunkSize; y++ {
+			tile, ok := placer.tmap.Get(x, y)
+			if ok && definition.tileTypes[tile.Type] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func pointTile(p proceduralgeneration.Point) tilemap.Point {
+	return tilemap.Point{X: int(math.Floor(p.X)), Y: int(math.Floor(p.Y))}
+}
+
+func (placer *ObjectPlacer) allowed(definition *ObjectDefinition, density random.Scatter, tile tilemap.Point) bool {
+	ground, ok := placer.tmap.Get(tile.X, tile.Y)
+	if !ok || !definition.tileTypes[ground.Type] || placer.tmap.Collides(tile.X, tile.Y) {
+		return false
+	}
+	biome, ok := Biomes.Get(placer.sampler.sample(tile.X, tile.Y).biome)
+	if !ok {
+		return false
+	}
+	return density.Roll(tile.X, tile.Y) < definition.Density[biome.Name]
+}
+
+// PlaceObjects creates an entity for every object placed on the tilemap. Chunks are placed
+// in parallel, but entities are always created in the same order.
+func PlaceObjects(engine *ecs.Engine, tmap *tilemap.Tilemap, config WorldConfig) {
+	PlaceObjectsParallel(engine, tmap, config, proceduralgeneration.DefaultWorkers)
+}
+
+// PlaceObjectsParallel places chunks on the given number of goroutines.
+func PlaceObjectsParallel(engine *ecs.Engine, tmap *tilemap.Tilemap, config WorldConfig, workers int) {
+	placer := NewObjectPlacer(tmap, config, WorldStreams(engine))
+	chunksX := (tmap.Width() + tilemap.DefaultChunkSize - 1) / tilemap.DefaultChunkSize
+	chunksY := (tmap.Height() + tilemap.DefaultChunkSize - 1) / tilemap.DefaultChunkSize
+
+	chunks := make([][]PlacedObject, chunksX*chunksY)
+	proceduralgeneration.Parallel(len(chunks), workers, func(i int) {
+		chunks[i] = placer.Chunk(i/chunksY, i%chunksY)
+	})
+
+	for _, objects := range chunks {
+		for _, object := range objects {
+			id := engine.NewId()
+			ecs.Write(engine, id, physics.Transform{X: object.X, Y: object.Y})
+			ecs.Write(engine, id, WorldObject{Name: object.Definition.Name, Kind: object.Definition.Kind})
+		}
+	}
+}
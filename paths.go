@@ -0,0 +1,143 @@
+package mmo
+
+import (
+	"gommo/engine/ecs"
+	"gommo/engine/pathfinding"
+	"gommo/engine/physics"
+	"gommo/engine/tilemap"
+	"math"
+	"time"
+)
+
+const (
+	pathWorkers   = 2
+	pathQueueSize = 64
+	// Followers that haven't moved for this many ticks are stuck on something and give up
+	pathStallTicks = 30
+)
+
+// PathFollower walks an entity along a path by setting its Input, so it moves by the same
+// rules, and through the same prediction, as the keyboard. An empty path means it is idle.
+type PathFollower struct {
+	Path []tilemap.Point
+
+	last    physics.Transform
+	stalled int
+}
+
+func (follower *PathFollower) ComponentSet(val interface{}) { *follower = val.(PathFollower) }
+
+// Paths searches for paths on worker goroutines, so clicking across the map never stalls
+// the game loop.
+type Paths struct {
+	engine *ecs.Engine
+	tmap   tilemap.Map
+	async  *pathfinding.Async
+}
+
+func NewPaths(engine *ecs.Engine, tmap tilemap.Map) *Paths {
+	pathfinder := pathfinding.New(tmap, TileCost)
+	return &Paths{
+		engine: engine,
+		tmap:   tmap,
+		async:  pathfinding.NewAsync(pathfinder, pathWorkers, pathQueueSize),
+	}
+}
+
+// MoveTo asks for a path from where an entity is to a world position. It returns false if
+// the request couldn't be queued. The entity starts following once the path is found.
+func (paths *Paths) MoveTo(id ecs.Id, x float64, y float64) bool {
+	transform := physics.Transform{}
+	if !ecs.Read(paths.engine, id, &transform) {
+		return false
+	}
+	startX, startY := paths.tmap.WorldToTile(transform.X, transform.Y)
+	goalX, goalY := paths.tmap.WorldToTile(x, y)
+	return paths.async.Submit(pathfinding.Request{
+		Id:    id,
+		Start: tilemap.Point{X: startX, Y: startY},
+		Goal:  tilemap.Point{X: goalX, Y: goalY},
+	})
+}
+
+func (paths *Paths) Close() {
+	paths.async.Close()
+}
+
+// CreatePathSystems hands found paths to their entities and steers every follower. They
+// belong after keyboard input is captured, so pressing a key takes over from a path.
+func CreatePathSystems(engine *ecs.Engine, paths *Paths) []ecs.System {
+	return []ecs.System{
+		{Name: "ReceivePaths", Func: receivePathsFunc(engine, paths)},
+		{Name: "FollowPaths", Func: followPathsFunc(engine, paths.tmap)},
+	}
+}
+
+func receivePathsFunc(engine *ecs.Engine, paths *Paths) func(dt time.Duration) {
+	return func(dt time.Duration) {
+		for _, result := range paths.async.Poll() {
+			if result.Err != nil {
+				continue
+			}
+			// The path starts on the tile the entity was standing on
+			ecs.Write(engine, result.Id, PathFollower{Path: result.Path[1:]})
+		}
+	}
+}
+
+func followPathsFunc(engine *ecs.Engine, tmap tilemap.Map) func(dt time.Duration) {
+	return func(dt time.Duration) {
+		ecs.Each(engine, PathFollower{}, func(id ecs.Id, a interface{}) {
+			follower := a.(PathFollower)
+			if len(follower.Path) == 0 {
+				return
+			}
+
+			input := physics.Input{}
+			transform := physics.Transform{}
+			if !ecs.Read(engine, id, &input) || !ecs.Read(engine, id, &transform) {
+				return
+			}
+			if input != (physics.Input{}) {
+				ecs.Write(engine, id, PathFollower{})
+				return
+			}
+
+			if transform == follower.last {
+				follower.stalled++
+			} else {
+				follower.stalled = 0
+			}
+			follower.last = transform
+
+			input = steer(tmap, transform, &follower.Path)
+			if len(follower.Path) == 0 || follower.stalled > pathStallTicks {
+				follower = PathFollower{}
+				input = physics.Input{}
+			}
+			ecs.Write(engine, id, input)
+			ecs.Write(engine, id, follower)
+		})
+	}
+}
+
+// steer returns the input moving towards the next waypoint, dropping waypoints once they
+// are closer than a single step.
+func steer(tmap tilemap.Map, transform physics.Transform, path *[]tilemap.Point) physics.Input {
+	for len(*path) > 0 {
+		waypoint := (*path)[0]
+		x, y := tmap.TileToWorld(waypoint.X, waypoint.Y)
+		dx, dy := x-transform.X, y-transform.Y
+		if math.Abs(dx) < physics.MoveSpeed && math.Abs(dy) < physics.MoveSpeed {
+			*path = (*path)[1:]
+			continue
+		}
+		return physics.Input{
+			Left:  dx <= -physics.MoveSpeed,
+			Right: dx >= physics.MoveSpeed,
+			Down:  dy <= -physics.MoveSpeed,
+			Up:    dy >= physics.MoveSpeed,
+		}
+	}
+	return physics.Input{}
+}
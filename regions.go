@@ -0,0 +1,183 @@
+package mmo
+
+import (
+	"gommo/engine/ecs"
+	"gommo/engine/physics"
+	"gommo/engine/random"
+	"gommo/engine/tilemap"
+	"strings"
+)
+
+const (
+	minIsletSize       = 64
+	minNamedRegionSize = 256
+)
+
+type RegionKind uint8
+
+const (
+	IslandRegion RegionKind = iota
+	LakeRegion
+	TownRegion
+)
+
+// Region marks a named landmass or lake, positioned at the center of its bounds.
+type Region struct {
+	Name   string
+	Kind   RegionKind
+	Size   int
+	Bounds tilemap.Rect
+}
+
+func (region *Region) ComponentSet(val interface{}) { *region = val.(Region) }
+
+func isLand(tile tilemap.Tile) bool {
+	return tile.Type != WaterTile
+}
+
+func isWater(tile tilemap.Tile) bool {
+	return tile.Type == WaterTile
+}
+
+// removeIslets floods every landmass too small to be worth visiting, along with anything
+// standing on it.
+func removeIslets(tmap *tilemap.Tilemap) {
+	regions := tmap.LabelRegions(isLand)
+	cells := []tilemap.Cell{}
+	for _, region := range regions.Regions {
+		if region.Size >= minIsletSize {
+			continue
+		}
+		for _, p := range regions.Points(region.Id) {
+			cells = append(cells,
+				tilemap.Cell{Layer: tilemap.GroundLayer, X: p.X, Y: p.Y, Tile: tilemap.Tile{Type: WaterTile}},
+				tilemap.Cell{Layer: tilemap.DecorationLayer, X: p.X, Y: p.Y, Tile: tilemap.Tile{Type: tilemap.EmptyTile}},
+				tilemap.Cell{Layer: tilemap.CollisionLayer, X: p.X, Y: p.Y, Tile: tilemap.Tile{Type: tilemap.EmptyTile}},
+			)
+		}
+	}
+	tmap.Apply(cells)
+}
+
+// nearestTile searches outwards from target in square rings, walking only the perimeter of
+// each ring, until it finds the matching tile closest to target. A tile on ring r is at
+// least r away, so once a match is found only the rings that could hold a closer one are
+// searched.
+func nearestTile(tmap tilemap.Map, target tilemap.Point, match func(p tilemap.Point) bool) (tilemap.Point, bool) {
+	maxRadius := tmap.Width()
+	if tmap.Height() > maxRadius {
+		maxRadius = tmap.Height()
+	}
+
+	best, found := target, false
+	bestDist := 0
+	visit := func(dx, dy int) {
+		p := tilemap.Point{X: target.X + dx, Y: target.Y + dy}
+		dist := dx*dx + dy*dy
+		if (found && dist >= bestDist) || !match(p) {
+			return
+		}
+		best, bestDist, found = p, dist, true
+	}
+
+	visit(0, 0)
+	for r := 1; r <= maxRadius && (!found || r*r < bestDist); r++ {
+		for d := -r; d <= r; d++ {
+			visit(d, -r)
+			visit(d, r)
+		}
+		for d := -r + 1; d < r; d++ {
+			visit(-r, d)
+			visit(r, d)
+		}
+	}
+	return best, found
+}
+
+// nameRegions creates a Region entity for every sizeable island and lake.
+func nameRegions(engine *ecs.Engine, tmap *tilemap.Tilemap) {
+	streams := WorldStreams(engine)
+	addRegions(engine, tmap, streams, tmap.LabelRegions(isLand), IslandRegion)
+	addRegions(engine, tmap, streams, tmap.LabelRegions(isWater), LakeRegion)
+	addTowns(engine, tmap, streams)
+}
+
+func isPlaza(tile tilemap.Tile) bool {
+	return tile.Type == PlazaTile
+}
+
+// addTowns creates a Region entity for every town plaza, and SpawnMarker entities of type
+// "npc" where townsfolk should stand. Towns are found from their tiles, so maps loaded from
+// a file get them too.
+func addTowns(engine *ecs.Engine, tmap *tilemap.Tilemap, streams random.Streams) {
+	for _, region := range tmap.LabelRegions(isPlaza).Regions {
+		min := tmap.TileBounds(region.Min.X, region.Min.Y)
+		max := tmap.TileBounds(region.Max.X, region.Max.Y)
+		bounds := tilemap.Rect{MinX: min.MinX, MinY: min.MinY, MaxX: max.MaxX, MaxY: max.MaxY}
+		name := regionName(streams, region.Start, TownRegion)
+
+		id := engine.NewId()
+		ecs.Write(engine, id, physics.Transform{X: (bounds.MinX + bounds.MaxX) / 2, Y: (bounds.MinY + bounds.MaxY) / 2})
+		ecs.Write(engine, id, Region{Name: name, Kind: TownRegion, Size: region.Size, Bounds: bounds})
+
+		// Townsfolk stand on the corners of the plaza
+		corners := []tilemap.Point{region.Min, {X: region.Max.X, Y: region.Min.Y}, {X: region.Min.X, Y: region.Max.Y}, region.Max}
+		for _, corner := range corners {
+			x, y := tmap.TileToWorld(corner.X, corner.Y)
+			id := engine.NewId()
+			ecs.Write(engine, id, physics.Transform{X: x, Y: y})
+			ecs.Write(engine, id, SpawnMarker{Name: name, Type: "npc"})
+		}
+	}
+}
+
+func addRegions(engine *ecs.Engine, tmap *tilemap.Tilemap, streams random.Streams, regions *tilemap.Regions, kind RegionKind) {
+	for _, region := range regions.Regions {
+		// Water touching the edge of the map is the sea, not a lake
+		if region.Size < minNamedRegionSize || (kind == LakeRegion && region.TouchesEdge) {
+			continue
+		}
+
+		min := tmap.TileBounds(region.Min.X, region.Min.Y)
+		max := tmap.TileBounds(region.Max.X, region.Max.Y)
+		bounds := tilemap.Rect{MinX: min.MinX, MinY: min.MinY, MaxX: max.MaxX, MaxY: max.MaxY}
+
+		id := engine.NewId()
+		ecs.Write(engine, id, physics.Transform{X: (bounds.MinX + bounds.MaxX) / 2, Y: (bounds.MinY + bounds.MaxY) / 2})
+		ecs.Write(engine, id, Region{
+			Name:   regionName(streams, region.Start, kind),
+			Kind:   kind,
+			Size:   region.Size,
+			Bounds: bounds,
+		})
+	}
+}
+
+var (
+	nameSyllables = []string{"ka", "lo", "mi", "ra", "sen", "tor", "vel", "an", "dru", "el", "fen", "gal", "is", "mor", "thi", "ur"}
+	islandSuffix  = []string{" Isle", " Reach", " Hollow", " Point"}
+	lakeSuffix    = []string{" Lake", " Mere", " Pool", " Waters"}
+	townSuffix    = []string{"ford", "by", "wick", "stead"}
+)
+
+// regionName builds a name from syllables picked by a stream keyed by the region's first
+// tile, so it stays the same every time the world is generated from the same seed.
+func regionName(streams random.Streams, start tilemap.Point, kind RegionKind) string {
+	rng := streams.Stream(random.Key{Purpose: "regions/names", TileX: start.X, TileY: start.Y})
+
+	name := strings.Builder{}
+	syllables := 2 + rng.Intn(2)
+	for i := 0; i < syllables; i++ {
+		name.WriteString(nameSyllables[rng.Intn(len(nameSyllables))])
+	}
+
+	suffixes := islandSuffix
+	switch kind {
+	case LakeRegion:
+		suffixes = lakeSuffix
+	case TownRegion:
+		suffixes = townSuffix
+	}
+	title := strings.ToUpper(name.String()[:1]) + name.String()[1:]
+	return title + suffixes[rng.Intn(len(suffixes))]
+}
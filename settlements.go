@@ -0,0 +1,307 @@
+package mmo
+
+import (
+	"gommo/engine/pathfinding"
+	"gommo/engine/random"
+	"gommo/engine/tilemap"
+	"math"
+	"sort"
+)
+
+const (
+	// Towns are 3x3 blocks of townBlock tiles, with the plaza in the middle block and a
+	// building on most of the others
+	townBlock          = 5
+	townSize           = 3 * townBlock
+	townBuildingChance = 0.75
+	// Largest height difference allowed within a town's footprint
+	townFlatness = 0.03
+	// How far from the footprint the nearest water may be, in tiles
+	townWaterDistance = 16
+	townCandidateStep = 4
+
+	// Roads are only tried between towns this many tiles apart or closer
+	maxRoadDistance = 600
+	maxBridgeLength = 8
+	roadReuseCost   = 0.5
+	roadBridgeCost  = 6
+	roadClearCost   = 2
+	roadSlopeCost   = 200
+)
+
+type townSite struct {
+	center tilemap.Point
+	score  float64
+}
+
+// addSettlements places up to config.Towns.Count towns on flat land close to water, then
+// links them with roads along the cheapest routes over the terrain. Roads reuse the ones
+// already laid where they can, and become bridges where they cross water.
+func addSettlements(world *World, config WorldConfig, streams random.Streams) {
+	if config.Towns.Count == 0 {
+		return
+	}
+	sites := findTownSites(world, config.Towns)
+	buildings := streams.Scatter("towns/buildings")
+	for _, site := range sites {
+		stampTown(world.Tilemap, buildings, site.center)
+	}
+	addRoads(world, sites)
+}
+
+// findTownSites scores every candidate footprint by how flat it is and how close it is to
+// water, then greedily picks the best ones that are far enough apart. Footprints never
+// overlap, whatever the spacing.
+func findTownSites(world *World, towns TownConfig) []townSite {
+	tmap := world.Tilemap
+	water := tileDistances(tmap, isRiverOrSea)
+	half := townSize / 2
+
+	candidates := []townSite{}
+	for x := half; x < tmap.Width()-half; x += townCandidateStep {
+		for y := half; y < tmap.Height()-half; y += townCandidateStep {
+			center := tilemap.Point{X: x, Y: y}
+			distance := water[x*tmap.Height()+y]
+			if distance < 0 || distance > half+townWaterDistance {
+				continue
+			}
+			flatness, ok := footprintFlatness(world, center)
+			if !ok || flatness > townFlatness {
+				continue
+			}
+			score := flatness/townFlatness + float64(distance-half)/townWaterDistance
+			candidates = append(candidates, townSite{center: center, score: score})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score < candidates[j].score
+	})
+
+	sites := []townSite{}
+	for _, candidate := range candidates {
+		if len(sites) == towns.Count {
+			break
+		}
+		crowded := false
+		for _, site := range sites {
+			dx, dy := float64(site.center.X-candidate.center.X), float64(site.center.Y-candidate.center.Y)
+			if math.Sqrt(dx*dx+dy*dy) < towns.Spacing || site.center.Chebyshev(candidate.center) < townSize {
+				crowded = true
+				break
+			}
+		}
+		if !crowded {
+			sites = append(sites, candidate)
+		}
+	}
+	return sites
+}
+
+// footprintFlatness returns the height difference over the town footprint around center,
+// or false if any of its tiles can't be built on.
+func footprintFlatness(world *World, center tilemap.Point) (float64, bool) {
+	half := townSize / 2
+	min, max := math.Inf(1), math.Inf(-1)
+	for x := center.X - half; x <= center.X+half; x++ {
+		for y := center.Y - half; y <= center.Y+half; y++ {
+			tile, ok := world.Tilemap.Get(x, y)
+			if !ok || !buildable(tile) {
+				return 0, false
+			}
+			h := world.Heights.Get(x, y)
+			min, max = math.Min(min, h), math.Max(max, h)
+		}
+	}
+	return max - min, true
+}
+
+func buildable(tile tilemap.Tile) bool {
+	_, liquid := Tiles.Property(tile.Type, "liquid")
+	return Tiles.Walkable(tile.Type) && !liquid
+}
+
+func isRiverOrSea(tile tilemap.Tile) bool {
+	return tile.Type == WaterTile || tile.Type == ShallowsTile
+}
+
+// tileDistances returns the 4-connected distance from every tile to the nearest tile that
+// matches, or -1 if there are none.
+func tileDistances(tmap *tilemap.Tilemap, match tilemap.Predicate) []int {
+	distances := make([]int, tmap.Width()*tmap.Height())
+	queue := []tilemap.Point{}
+	for x := 0; x < tmap.Width(); x++ {
+		for y := 0; y < tmap.Height(); y++ {
+			distances[x*tmap.Height()+y] = -1
+			if tile, _ := tmap.Get(x, y); match(tile) {
+				distances[x*tmap.Height()+y] = 0
+				queue = append(queue, tilemap.Point{X: x, Y: y})
+			}
+		}
+	}
+	for i := 0; i < len(queue); i++ {
+		d := distances[queue[i].X*tmap.Height()+queue[i].Y]
+		for _, n := range tmap.Neighbors4(queue[i]) {
+			if distances[n.X*tmap.Height()+n.Y] == -1 {
+				distances[n.X*tmap.Height()+n.Y] = d + 1
+				queue = append(queue, n)
+			}
+		}
+	}
+	return distances
+}
+
+// stampTown lays out the blocks around the plaza. Every block other than the plaza is
+// ringed by road, with a building or a garden of the original ground inside.
+func stampTown(tmap *tilemap.Tilemap, buildings random.Scatter, center tilemap.Point) {
+	half := townSize / 2
+	cells := []tilemap.Cell{}
+	set := func(x, y int, tileType tilemap.TileType) {
+		cells = append(cells, tilemap.Cell{Layer: tilemap.GroundLayer, X: x, Y: y, Tile: tilemap.Tile{Type: tileType}})
+		collision := tilemap.Tile{Type: tilemap.EmptyTile}
+		if !Tiles.Walkable(tileType) {
+			collision = tilemap.Tile{Type: tileType}
+		}
+		cells = append(cells,
+			tilemap.Cell{Layer: tilemap.DecorationLayer, X: x, Y: y, Tile: tilemap.Tile{Type: tilemap.EmptyTile}},
+			tilemap.Cell{Layer: tilemap.CollisionLayer, X: x, Y: y, Tile: collision},
+		)
+	}
+
+	for bx := 0; bx < 3; bx++ {
+		for by := 0; by < 3; by++ {
+			originX, originY := center.X-half+bx*townBlock, center.Y-half+by*townBlock
+			plaza := bx == 1 && by == 1
+			building := buildings.Roll(originX, originY) < townBuildingChance
+			for dx := 0; dx < townBlock; dx++ {
+				for dy := 0; dy < townBlock; dy++ {
+					x, y := originX+dx, originY+dy
+					edge := dx == 0 || dy == 0 || dx == townBlock-1 || dy == townBlock-1
+					switch {
+					case plaza:
+						set(x, y, PlazaTile)
+					case edge:
+						set(x, y, RoadTile)
+					case building:
+						set(x, y, BuildingTile)
+					}
+				}
+			}
+		}
+	}
+	tmap.Apply(cells)
+}
+
+// addRoads connects the towns like a minimum spanning tree, trying the closest pairs first
+// and skipping pairs that are already linked through other towns.
+func addRoads(world *World, sites []townSite) {
+	type pair struct {
+		a, b     int
+		distance int
+	}
+	pairs := []pair{}
+	for a := range sites {
+		for b := a + 1; b < len(sites); b++ {
+			distance := sites[a].center.Manhattan(sites[b].center)
+			if distance <= maxRoadDistance {
+				pairs = append(pairs, pair{a, b, distance})
+			}
+		}
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return pairs[i].distance < pairs[j].distance
+	})
+
+	// Water further than this from the shore is too wide to bridge
+	shore := tileDistances(world.Tilemap, func(tile tilemap.Tile) bool { return !isRiverOrSea(tile) })
+
+	linked := make([]int, len(sites))
+	for i := range linked {
+		linked[i] = i
+	}
+	var root func(i int) int
+	root = func(i int) int {
+		if linked[i] != i {
+			linked[i] = root(linked[i])
+		}
+		return linked[i]
+	}
+
+	for _, pair := range pairs {
+		if root(pair.a) == root(pair.b) {
+			continue
+		}
+		if layRoad(world, shore, sites[pair.a].center, sites[pair.b].center) {
+			linked[root(pair.a)] = root(pair.b)
+		}
+	}
+}
+
+// layRoad paves the cheapest route between two towns, unless it would need a bridge longer
+// than maxBridgeLength.
+func layRoad(world *World, shore []int, from, to tilemap.Point) bool {
+	tmap := world.Tilemap
+	path, _, err := pathfinding.Cheapest(tmap.Width(), tmap.Height(), from, to, roadReuseCost, func(a, b tilemap.Point) (float64, bool) {
+		return roadCost(world, shore, a, b)
+	})
+	if err != nil {
+		return false
+	}
+
+	bridge := 0
+	for _, p := range path {
+		tile, _ := tmap.Get(p.X, p.Y)
+		if isRiverOrSea(tile) {
+			bridge++
+			if bridge > maxBridgeLength {
+				return false
+			}
+		} else {
+			bridge = 0
+		}
+	}
+
+	cells := []tilemap.Cell{}
+	for _, p := range path {
+		tile, _ := tmap.Get(p.X, p.Y)
+		tileType := RoadTile
+		switch {
+		case tile.Type == RoadTile || tile.Type == BridgeTile || tile.Type == PlazaTile:
+			continue
+		case isRiverOrSea(tile):
+			tileType = BridgeTile
+		}
+		cells = append(cells,
+			tilemap.Cell{Layer: tilemap.GroundLayer, X: p.X, Y: p.Y, Tile: tilemap.Tile{Type: tileType}},
+			tilemap.Cell{Layer: tilemap.DecorationLayer, X: p.X, Y: p.Y, Tile: tilemap.Tile{Type: tilemap.EmptyTile}},
+			tilemap.Cell{Layer: tilemap.CollisionLayer, X: p.X, Y: p.Y, Tile: tilemap.Tile{Type: tilemap.EmptyTile}},
+		)
+	}
+	tmap.Apply(cells)
+	return true
+}
+
+// roadCost prefers existing roads, then easy ground, and climbs and bridges as little as it
+// can. Decorations in the way are cleared at a cost, and only narrow water is bridged.
+func roadCost(world *World, shore []int, from, to tilemap.Point) (float64, bool) {
+	tile, _ := world.Tilemap.Get(to.X, to.Y)
+	cost := 0.0
+	switch {
+	case tile.Type == RoadTile || tile.Type == BridgeTile || tile.Type == PlazaTile:
+		return roadReuseCost, true
+	case isRiverOrSea(tile):
+		if shore[to.X*world.Tilemap.Height()+to.Y] > maxBridgeLength/2 {
+			return 0, false
+		}
+		cost = roadBridgeCost
+	default:
+		movement, ok := Tiles.Cost(tile)
+		if !ok {
+			return 0, false
+		}
+		cost = movement
+		if world.Tilemap.Collides(to.X, to.Y) {
+			cost += roadClearCost
+		}
+	}
+	return cost + math.Abs(world.Heights.Get(to.X, to.Y)-world.Heights.Get(from.X, from.Y))*roadSlopeCost, true
+}
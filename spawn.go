@@ -0,0 +1,175 @@
+package mmo
+
+import (
+	"errors"
+	"fmt"
+	"gommo/engine/physics"
+	"gommo/engine/random"
+	"gommo/engine/tilemap"
+	"math"
+	"math/rand"
+	"sync"
+)
+
+const (
+	// Random tiles tried around a spawn target before searching outwards for land
+	spawnAttempts = 32
+	// Walkable areas smaller than this are pockets players could get stuck in
+	minSpawnAreaSize = minIsletSize
+)
+
+var ErrNoSpawnTile = errors.New("no walkable tile to spawn on")
+
+// SpawnRegion is a named area players can be spawned in. X and Y are fractions of the map
+// size so the same regions work for any mapSize, and Spread is the radius in tiles players
+// are scattered over so they don't stack on top of each other.
+type SpawnRegion struct {
+	Name   string  `json:"name"`
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Spread int     `json:"spread"`
+}
+
+func (region SpawnRegion) target(tmap tilemap.Map) tilemap.Point {
+	return tilemap.Point{
+		X: int(math.Round(region.X * float64(tmap.Width()-1))),
+		Y: int(math.Round(region.Y * float64(tmap.Height()-1))),
+	}
+}
+
+// Spawner picks walkable tiles for players to spawn on. It is safe to use from multiple
+// goroutines.
+type Spawner struct {
+	tilemap tilemap.Map
+	regions []SpawnRegion
+
+	mu  sync.Mutex
+	rng *rand.Rand
+	// Walkable areas of the map, labelled lazily and dropped whenever the map changes.
+	// Labelling needs the whole map, so chunked maps go without.
+	areas *tilemap.Regions
+}
+
+func NewSpawner(tmap tilemap.Map, config WorldConfig, streams random.Streams) *Spawner {
+	spawner := &Spawner{
+		tilemap: tmap,
+		regions: config.SpawnRegions,
+		rng:     streams.Stream(random.Key{Purpose: "spawn"}),
+	}
+	editable, ok := tmap.(tilemap.Editable)
+	if ok {
+		editable.OnChange(func(change tilemap.Change) {
+			spawner.mu.Lock()
+			spawner.areas = nil
+			spawner.mu.Unlock()
+		})
+	}
+	return spawner
+}
+
+// Region returns the spawn region with the given name. The empty name is the first region,
+// or the center of the map when there are none.
+func (spawner *Spawner) Region(name string) (SpawnRegion, bool) {
+	if name == "" {
+		if len(spawner.regions) == 0 {
+			return SpawnRegion{Name: "center", X: 0.5, Y: 0.5}, true
+		}
+		return spawner.regions[0], true
+	}
+	for _, region := range spawner.regions {
+		if region.Name == name {
+			return region, true
+		}
+	}
+	return SpawnRegion{}, false
+}
+
+// Spawn returns a random walkable position within the named region's spread. If there is
+// none it falls back to the closest walkable tile, preferring the largest landmass.
+func (spawner *Spawner) Spawn(name string) (physics.Transform, error) {
+	region, ok := spawner.Region(name)
+	if !ok {
+		return physics.Transform{}, fmt.Errorf("unknown spawn region %q", name)
+	}
+
+	spawner.mu.Lock()
+	defer spawner.mu.Unlock()
+
+	target := region.target(spawner.tilemap)
+	p, ok := spawner.scatter(target, region.Spread)
+	if !ok {
+		target, ok = spawner.fallback(target)
+		if !ok {
+			return physics.Transform{}, ErrNoSpawnTile
+		}
+		p, _ = spawner.scatter(target, region.Spread)
+	}
+
+	x, y := spawner.tilemap.TileToWorld(p.X, p.Y)
+	return physics.Transform{X: x, Y: y}, nil
+}
+
+// scatter tries random tiles in a circle of radius spread around target. It returns target
+// itself if that's the only candidate it could find.
+func (spawner *Spawner) scatter(target tilemap.Point, spread int) (tilemap.Point, bool) {
+	for i := 0; i < spawnAttempts && spread > 0; i++ {
+		angle := spawner.rng.Float64() * 2 * math.Pi
+		r := math.Sqrt(spawner.rng.Float64()) * float64(spread)
+		p := tilemap.Point{
+			X: target.X + int(math.Round(r*math.Cos(angle))),
+			Y: target.Y + int(math.Round(r*math.Sin(angle))),
+		}
+		if spawner.canSpawn(p) {
+			return p, true
+		}
+	}
+	return target, spawner.canSpawn(target)
+}
+
+// fallback finds the closest tile of the largest walkable area, or failing that the closest
+// walkable tile at all. Chunked maps only have the latter.
+func (spawner *Spawner) fallback(target tilemap.Point) (tilemap.Point, bool) {
+	areas, ok := spawner.walkableAreas()
+	if ok {
+		main, ok := areas.Largest()
+		if ok {
+			p, found := nearestTile(spawner.tilemap, target, func(p tilemap.Point) bool {
+				return areas.Label(p.X, p.Y) == main.Id && !spawner.tilemap.Collides(p.X, p.Y)
+			})
+			if found {
+				return p, true
+			}
+		}
+	}
+	return nearestTile(spawner.tilemap, target, spawner.walkable)
+}
+
+func (spawner *Spawner) canSpawn(p tilemap.Point) bool {
+	if !spawner.walkable(p) {
+		return false
+	}
+	areas, ok := spawner.walkableAreas()
+	if !ok {
+		return true
+	}
+	area, ok := areas.At(p.X, p.Y)
+	return ok && area.Size >= minSpawnAreaSize
+}
+
+func (spawner *Spawner) walkable(p tilemap.Point) bool {
+	tile, ok := spawner.tilemap.Get(p.X, p.Y)
+	return ok && Tiles.Walkable(tile.Type) && !spawner.tilemap.Collides(p.X, p.Y)
+}
+
+func (spawner *Spawner) walkableAreas() (*tilemap.Regions, bool) {
+	whole, ok := spawner.tilemap.(*tilemap.Tilemap)
+	if !ok {
+		return nil, false
+	}
+	if spawner.areas == nil {
+		spawner.areas = whole.LabelRegions(func(tile tilemap.Tile) bool {
+			return Tiles.Walkable(tile.Type)
+		})
+	}
+	return spawner.areas, true
+}
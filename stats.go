@@ -0,0 +1,41 @@
+package mmo
+
+import (
+	"gommo/engine/tilemap"
+)
+
+type MapStats struct {
+	Tiles map[tilemap.TileType]int
+	// Share of the map that isn't water, from 0 to 1
+	Land    float64
+	Islands int
+	// Bodies of water that don't touch the edge of the map
+	Lakes int
+	Towns int
+}
+
+func Stats(tmap *tilemap.Tilemap) MapStats {
+	stats := MapStats{Tiles: make(map[tilemap.TileType]int)}
+	land := 0
+	for x := 0; x < tmap.Width(); x++ {
+		for y := 0; y < tmap.Height(); y++ {
+			tile, _ := tmap.Get(x, y)
+			stats.Tiles[tile.Type]++
+			if isLand(tile) {
+				land++
+			}
+		}
+	}
+	if total := tmap.Width() * tmap.Height(); total > 0 {
+		stats.Land = float64(land) / float64(total)
+	}
+
+	stats.Islands = len(tmap.LabelRegions(isLand).Regions)
+	for _, region := range tmap.LabelRegions(isWater).Regions {
+		if !region.TouchesEdge {
+			stats.Lakes++
+		}
+	}
+	stats.Towns = len(tmap.LabelRegions(isPlaza).Regions)
+	return stats
+}